@@ -0,0 +1,102 @@
+package gmime
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// checkParsedAddresses compares addresses of parsed message with want, in the order ParseBytes returns them
+func checkParsedAddresses(t *testing.T, parsed *ParsedMessage, want []EmailAddress) {
+	var got []EmailAddress
+	for _, a := range parsed.Addresses {
+		got = append(got, *a)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("addresses\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestBccAndGroups(t *testing.T) {
+	m := NewMessage()
+	for _, a := range []*EmailAddress{
+		{AddressType: AddressFrom, Address: "list@example.com"},
+		{AddressType: AddressSender, Address: "bounce@example.com"},
+		{AddressType: AddressTo, Address: "a@example.org"},
+		{AddressType: AddressTo, Address: "d@example.org", Group: "Team"},
+		{AddressType: AddressTo, Address: "e@example.org", Group: "Team"},
+		{AddressType: AddressCC, Address: "b@example.org"},
+		{AddressType: AddressBCC, Address: "hidden@example.org"},
+		{AddressType: AddressBCC, Address: "A@example.org"},
+	} {
+		m.AddAddress(a)
+	}
+	m.SetText([]byte("text"))
+	data, parsed := exportParsed(t, m)
+
+	if bytes.Contains(bytes.ToLower(data), []byte("bcc:")) || bytes.Contains(data, []byte("hidden@example.org")) {
+		t.Errorf("export has Bcc:\n%s", data)
+	}
+	want := []string{"a@example.org", "d@example.org", "e@example.org", "b@example.org", "hidden@example.org"}
+	if got := m.EnvelopeRecipients(); !reflect.DeepEqual(got, want) {
+		t.Errorf("EnvelopeRecipients %q, want %q", got, want)
+	}
+	checkParsedAddresses(t, parsed, []EmailAddress{
+		{AddressType: AddressFrom, Address: "list@example.com"},
+		{AddressType: AddressTo, Address: "a@example.org"},
+		{AddressType: AddressTo, Address: "d@example.org", Group: "Team"},
+		{AddressType: AddressTo, Address: "e@example.org", Group: "Team"},
+		{AddressType: AddressCC, Address: "b@example.org"},
+		{AddressType: AddressSender, Address: "bounce@example.com"},
+	})
+}
+
+func TestUndisclosedRecipients(t *testing.T) {
+	m := NewMessage()
+	m.AddAddress(&EmailAddress{AddressType: AddressFrom, Address: "list@example.com"})
+	m.AddAddress(&EmailAddress{AddressType: AddressTo, Group: "undisclosed-recipients"})
+	m.AddAddress(&EmailAddress{AddressType: AddressBCC, Address: "hidden@example.org"})
+	m.SetText([]byte("text"))
+	_, parsed := exportParsed(t, m)
+
+	if got := headerValues(parsed, "To"); len(got) != 1 || strings.Replace(got[0], " ", "", -1) != "undisclosed-recipients:;" {
+		t.Errorf("To %q", got)
+	}
+	if got := m.EnvelopeRecipients(); !reflect.DeepEqual(got, []string{"hidden@example.org"}) {
+		t.Errorf("EnvelopeRecipients %q", got)
+	}
+}
+
+func TestResentAddresses(t *testing.T) {
+	m := NewMessage()
+	for _, a := range []*EmailAddress{
+		{AddressType: AddressFrom, Address: "author@example.com"},
+		{AddressType: AddressTo, Address: "original@example.org"},
+		{AddressType: AddressResentFrom, Address: "forwarder@example.com"},
+		{AddressType: AddressResentSender, Address: "robot@example.com"},
+		{AddressType: AddressResentTo, Address: "rt@example.org"},
+		{AddressType: AddressResentCC, Address: "rc@example.org"},
+		{AddressType: AddressResentBCC, Address: "rb@example.org"},
+	} {
+		m.AddAddress(a)
+	}
+	m.SetText([]byte("text"))
+	data, parsed := exportParsed(t, m)
+
+	if bytes.Contains(bytes.ToLower(data), []byte("resent-bcc:")) || bytes.Contains(data, []byte("rb@example.org")) {
+		t.Errorf("export has Resent-Bcc:\n%s", data)
+	}
+	want := []string{"rt@example.org", "rc@example.org", "rb@example.org"}
+	if got := m.EnvelopeRecipients(); !reflect.DeepEqual(got, want) {
+		t.Errorf("EnvelopeRecipients %q, want %q", got, want)
+	}
+	checkParsedAddresses(t, parsed, []EmailAddress{
+		{AddressType: AddressFrom, Address: "author@example.com"},
+		{AddressType: AddressTo, Address: "original@example.org"},
+		{AddressType: AddressResentFrom, Address: "forwarder@example.com"},
+		{AddressType: AddressResentSender, Address: "robot@example.com"},
+		{AddressType: AddressResentTo, Address: "rt@example.org"},
+		{AddressType: AddressResentCC, Address: "rc@example.org"},
+	})
+}
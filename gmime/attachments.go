@@ -11,13 +11,16 @@ import "C"
 import (
 	"mime"
 	"path/filepath"
-	"strings"
+	"strconv"
+	"time"
 	"unsafe"
 )
 
-func addAttachments(obj *C.GMimeMultipart, attaches []*EmailAttachment) {
+// addAttachments returns streams created for EmailAttachment.Reader,
+// their errors are known only after the message is written
+func addAttachments(obj *C.GMimeMultipart, attaches []*EmailAttachment, fileNameEncoding FileNameEncoding) []*goStream {
+	var readers []*goStream
 	for _, e := range attaches {
-		text := (*C.char)(unsafe.Pointer(&e.Content[0]))
 		inputEncoding := e.InputEncoding
 		outputEncoding := e.OutputEncoding
 		if inputEncoding == nil {
@@ -26,9 +29,21 @@ func addAttachments(obj *C.GMimeMultipart, attaches []*EmailAttachment) {
 		if outputEncoding == nil {
 			outputEncoding = &EncodingBase64
 		}
-		mem := C.g_mime_stream_mem_new_with_buffer(text, C.strlen(text))                                // needs unref
-		content := C.g_mime_data_wrapper_new_with_stream(mem, (C.GMimeContentEncoding)(*inputEncoding)) // needs unref
-		C.g_object_unref(mem)                                                                           // unref
+		var content *C.GMimeDataWrapper
+		if e.Reader != nil {
+			// content is read lazily when the message is written
+			reader := &goStream{r: attachmentReader{e}}
+			readers = append(readers, reader)
+			mem := newGoStream(reader) // needs unref
+			if e.Size > 0 {
+				C.g_mime_stream_set_bounds(mem, 0, C.gint64(e.Size))
+			}
+			content = C.g_mime_data_wrapper_new_with_stream(mem, (C.GMimeContentEncoding)(*inputEncoding)) // needs unref
+			C.g_object_unref(mem)                                                                          // unref
+		} else {
+			// exact length, Content may be empty or contain NUL bytes
+			content = dataWrapperFromBytes(e.Content, *inputEncoding) // needs unref
+		}
 
 		mediaType := e.MimeType
 		if mediaType == "" {
@@ -38,9 +53,10 @@ func addAttachments(obj *C.GMimeMultipart, attaches []*EmailAttachment) {
 			mediaType = "application/octet-stream"
 		}
 
-		mimeSplit := strings.Split(mediaType, "/")
-		cStringMimeType := C.CString(mimeSplit[0])                               // needs free
-		cStringMimeSubType := C.CString(mimeSplit[1])                            // needs free
+		// MimeType is checked by validateAttachment, TypeByExtension always has a subtype
+		mediaType, mediaSubtype, _ := splitMediaType(mediaType)
+		cStringMimeType := C.CString(mediaType)                                  // needs free
+		cStringMimeSubType := C.CString(mediaSubtype)                            // needs free
 		part := C.g_mime_part_new_with_type(cStringMimeType, cStringMimeSubType) // needs unref
 		partObject := anyToGMimeObject(unsafe.Pointer(part))
 		C.free(unsafe.Pointer(cStringMimeType))    // free
@@ -62,14 +78,44 @@ func addAttachments(obj *C.GMimeMultipart, attaches []*EmailAttachment) {
 		C.g_mime_object_set_disposition(partObject, disposition)
 		C.free(unsafe.Pointer(disposition)) // free
 
+		if e.DispositionSize > 0 {
+			setDispositionParameter(partObject, "size", strconv.FormatInt(e.DispositionSize, 10))
+		}
+		for _, date := range []struct {
+			name  string
+			value time.Time
+		}{{"creation-date", e.CreationDate}, {"modification-date", e.ModificationDate}} {
+			if !date.value.IsZero() {
+				setDispositionParameter(partObject, date.name, date.value.Format(time.RFC1123Z))
+			}
+		}
+
 		if e.FileName != "" {
-			fileName := C.CString(e.FileName) // needs free
-			C.g_mime_part_set_filename(part, fileName)
-			C.free(unsafe.Pointer(fileName)) // free
+			setFileName(part, e.FileName, fileNameEncoding)
 		}
 
+		appendPartHeaders(partObject, e.Headers)
+
 		C.g_mime_multipart_add(obj, partObject)
 		C.g_object_unref(part) // unref
 	}
+	return readers
+}
+
+func setDispositionParameter(obj *C.GMimeObject, name, value string) {
+	cName := C.CString(name)   // needs free
+	cValue := C.CString(value) // needs free
+	C.g_mime_object_set_content_disposition_parameter(obj, cName, cValue)
+	C.free(unsafe.Pointer(cName))  // free
+	C.free(unsafe.Pointer(cValue)) // free
+}
+
+// attachmentReader reads Reader of attachment and marks it consumed
+type attachmentReader struct {
+	attachment *EmailAttachment
+}
 
+func (r attachmentReader) Read(p []byte) (int, error) {
+	r.attachment.readerConsumed = true
+	return r.attachment.Reader.Read(p)
 }
@@ -0,0 +1,157 @@
+package gmime
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime"
+	"testing"
+	"time"
+)
+
+func TestAttachmentContentRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+	}{
+		{"nul bytes", []byte("%PDF-1.4\x00\x00binary\x00\xff\xfe\x00tail")},
+		{"only nul", []byte{0}},
+		{"empty", []byte{}},
+		{"nil", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMessage()
+			m.SetText([]byte("see attachment"))
+			m.Attach(&EmailAttachment{
+				FileName: "file.bin",
+				MimeType: "application/octet-stream",
+				Content:  tt.content,
+			})
+			data, err := m.Export()
+			if err != nil {
+				t.Fatalf("Export: %v", err)
+			}
+
+			parsed, err := ParseBytes(data)
+			if err != nil {
+				t.Fatalf("ParseBytes: %v", err)
+			}
+			if len(parsed.Attachments) != 1 {
+				t.Fatalf("got %d attachments, want 1", len(parsed.Attachments))
+			}
+			got := parsed.Attachments[0].Content
+			if len(got) != len(tt.content) || !bytes.Equal(got, tt.content) {
+				t.Errorf("content = %q, want %q", got, tt.content)
+			}
+		})
+	}
+}
+
+func TestAttachmentReaderExportedOnce(t *testing.T) {
+	m := NewMessage()
+	m.SetText([]byte("see attachment"))
+	m.Attach(&EmailAttachment{
+		FileName: "file.bin",
+		MimeType: "application/octet-stream",
+		Reader:   bytes.NewReader([]byte("streamed\x00content")),
+	})
+	data, err := m.Export()
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	parsed, err := ParseBytes(data)
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+	if len(parsed.Attachments) != 1 || string(parsed.Attachments[0].Content) != "streamed\x00content" {
+		t.Fatalf("got attachments %+v", parsed.Attachments)
+	}
+
+	if _, err := m.Export(); err != ErrReaderConsumed {
+		t.Errorf("second Export: got %v, want ErrReaderConsumed", err)
+	}
+	if _, err := m.WriteTo(ioutil.Discard); err != ErrReaderConsumed {
+		t.Errorf("WriteTo after Export: got %v, want ErrReaderConsumed", err)
+	}
+}
+
+func TestAttachmentDispositionParams(t *testing.T) {
+	created := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	modified := time.Date(2024, 3, 2, 11, 30, 0, 0, time.FixedZone("", 2*3600))
+	m := NewMessage()
+	m.SetText([]byte("see attachment"))
+	m.Attach(&EmailAttachment{
+		FileName:         "report.pdf",
+		MimeType:         "application/pdf",
+		Disposition:      "attachment",
+		Content:          []byte("%PDF"),
+		DispositionSize:  4,
+		CreationDate:     created,
+		ModificationDate: modified,
+		Headers: []*EmailHeader{
+			{Name: "Content-Description", Value: "Quarterly report"},
+			{Name: "X-Attachment-Id", Value: "f_1"},
+		},
+	})
+	_, parsed := exportParsed(t, m)
+	if len(parsed.Root.Children) != 2 {
+		t.Fatalf("got %d parts, want 2", len(parsed.Root.Children))
+	}
+	part := parsed.Root.Children[1]
+
+	headers := map[string][]string{}
+	for _, h := range part.Headers {
+		headers[h.Name] = append(headers[h.Name], h.Value)
+	}
+	if got := headers["Content-Disposition"]; len(got) != 1 {
+		t.Fatalf("Content-Disposition %q, want one", got)
+	}
+	disposition, params, err := mime.ParseMediaType(headers["Content-Disposition"][0])
+	if err != nil {
+		t.Fatalf("Content-Disposition: %v", err)
+	}
+	want := map[string]string{
+		"filename":          "report.pdf",
+		"size":              "4",
+		"creation-date":     created.Format(time.RFC1123Z),
+		"modification-date": modified.Format(time.RFC1123Z),
+	}
+	if disposition != "attachment" || len(params) != len(want) {
+		t.Errorf("got %s %v, want attachment %v", disposition, params, want)
+	}
+	for name, value := range want {
+		if params[name] != value {
+			t.Errorf("%s=%q, want %q", name, params[name], value)
+		}
+	}
+	for name, value := range map[string]string{"Content-Description": "Quarterly report", "X-Attachment-Id": "f_1"} {
+		if got := headers[name]; len(got) != 1 || got[0] != value {
+			t.Errorf("%s %q, want %q", name, got, value)
+		}
+	}
+}
+
+func TestAttachmentGeneratedHeaders(t *testing.T) {
+	for _, name := range []string{"Content-Disposition", "content-type", "Content-Transfer-Encoding"} {
+		m := NewMessage()
+		m.SetText([]byte("see attachment"))
+		m.Attach(&EmailAttachment{FileName: "a.txt", Content: []byte("a"), Headers: []*EmailHeader{{Name: name, Value: "inline"}}})
+		if _, err := m.Export(); !isHeaderError(err, name, ErrHeaderGenerated) {
+			t.Errorf("%s: got %v, want ErrHeaderGenerated", name, err)
+		}
+	}
+
+	a := &EmailAttachment{FileName: "a.png", Content: []byte("a"), Headers: []*EmailHeader{{Name: "Content-Id", Value: "<b@example.com>"}}}
+	if err := validateAttachment(a); err != nil {
+		t.Errorf("Content-Id without ContentID: %v", err)
+	}
+	a.ContentID = "a@example.com"
+	if err := validateAttachment(a); !isHeaderError(err, "Content-Id", ErrHeaderGenerated) {
+		t.Errorf("Content-Id with ContentID: got %v, want ErrHeaderGenerated", err)
+	}
+}
+
+func isHeaderError(err error, name string, want error) bool {
+	he, ok := err.(*HeaderError)
+	return ok && he.Name == name && he.Err == want
+}
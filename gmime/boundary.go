@@ -0,0 +1,89 @@
+package gmime
+
+/*
+#cgo pkg-config: gmime-2.6
+#include <stdlib.h>
+#include <gmime/gmime.h>
+*/
+import "C"
+import (
+	"crypto/rand"
+	"encoding/base64"
+	mathrand "math/rand"
+	"unsafe"
+)
+
+// BoundaryGenerator returns boundaries of multiparts created for exported messages
+type BoundaryGenerator interface {
+	Boundary() string
+}
+
+type randomBoundaries struct{}
+
+// RandomBoundaries returns generator of securely random boundaries, it is the default
+func RandomBoundaries() BoundaryGenerator {
+	return randomBoundaries{}
+}
+
+func (randomBoundaries) Boundary() string {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		// system random failed, fall back to boundary generated by GMime
+		return ""
+	}
+	return boundaryFromBytes(b)
+}
+
+type seededBoundaries struct {
+	seed int64
+	rand *mathrand.Rand
+}
+
+// SeededBoundaries returns generator of the same sequence of boundaries for the same seed,
+// so output can be compared with golden files. Every export of a message set with
+// SetBoundaryGenerator starts the sequence again, so it gets the same boundaries every time.
+// Boundary called directly is not safe for concurrent use
+func SeededBoundaries(seed int64) BoundaryGenerator {
+	return &seededBoundaries{seed: seed, rand: mathrand.New(mathrand.NewSource(seed))}
+}
+
+func (g *seededBoundaries) Boundary() string {
+	b := make([]byte, 24)
+	g.rand.Read(b)
+	return boundaryFromBytes(b)
+}
+
+// "=-" can't appear in base64 or quoted-printable content
+func boundaryFromBytes(b []byte) string {
+	return "=-" + base64.RawURLEncoding.EncodeToString(b)
+}
+
+// SetBoundaryGenerator sets generator of boundaries for all multiparts of exported message,
+// nil restores RandomBoundaries
+func (m *Message) SetBoundaryGenerator(g BoundaryGenerator) {
+	m.boundaries = g
+}
+
+// boundaryGenerator returns generator for one export
+func (m *Message) boundaryGenerator() BoundaryGenerator {
+	switch g := m.boundaries.(type) {
+	case nil:
+		return RandomBoundaries()
+	case *seededBoundaries:
+		// reseeded, so every export has the same boundaries
+		return SeededBoundaries(g.seed)
+	default:
+		return g
+	}
+}
+
+// caller responsible for unref
+func newMultiPartWithSubtype(subtype *C.char, boundaries BoundaryGenerator) *C.GMimeMultipart {
+	p := C.g_mime_multipart_new_with_subtype(subtype)
+	if boundary := boundaries.Boundary(); boundary != "" {
+		b := C.CString(boundary)        // needs free
+		defer C.free(unsafe.Pointer(b)) // free
+		C.g_mime_multipart_set_boundary(p, b)
+	}
+	return p
+}
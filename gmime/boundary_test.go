@@ -0,0 +1,59 @@
+package gmime
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func newTestBoundaryMessage(seed int64) *Message {
+	m := NewMessage()
+	m.AddAddress(&EmailAddress{AddressType: AddressFrom, Address: "sender@example.com"})
+	m.SetHeaderDefaults(&HeaderDefaults{
+		MessageIDGenerator: func(domain string) string { return "golden@" + domain },
+		Clock:              func() time.Time { return time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC) },
+	})
+	m.SetText([]byte("text"))
+	m.SetHtml([]byte("<p>html</p>"))
+	m.Attach(&EmailAttachment{FileName: "a.txt", MimeType: "text/plain", Content: []byte("attachment")})
+	m.SetBoundaryGenerator(SeededBoundaries(seed))
+	return m
+}
+
+func TestSeededBoundaries(t *testing.T) {
+	m := newTestBoundaryMessage(42)
+	first, err := m.Export()
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	second, err := m.Export()
+	if err != nil {
+		t.Fatalf("second Export: %v", err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Errorf("exports of one message differ:\n%s\n\n%s", first, second)
+	}
+	other, err := newTestBoundaryMessage(42).Export()
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if !bytes.Equal(first, other) {
+		t.Errorf("messages with the same seed differ:\n%s\n\n%s", first, other)
+	}
+
+	// mixed and alternative multiparts take the first two boundaries of the sequence
+	g := SeededBoundaries(42)
+	for _, boundary := range []string{g.Boundary(), g.Boundary()} {
+		if !bytes.Contains(first, []byte("--"+boundary+"\n")) {
+			t.Errorf("export has no boundary %s:\n%s", boundary, first)
+		}
+	}
+
+	seed7, err := newTestBoundaryMessage(7).Export()
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if bytes.Equal(first, seed7) {
+		t.Error("messages with different seeds are the same")
+	}
+}
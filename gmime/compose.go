@@ -9,7 +9,10 @@ import "C"
 import (
 	"errors"
 	"fmt"
+	"io"
 	"reflect"
+	"strings"
+	"time"
 	"unsafe"
 )
 
@@ -32,23 +35,35 @@ var (
 )
 
 var (
-	ErrNoContent = errors.New("No content (text or html)")
-	ErrWrite     = errors.New("Error writing message to stream")
+	ErrNoContent      = errors.New("No content (text or html)")
+	ErrWrite          = errors.New("Error writing message to stream")
+	ErrReaderConsumed = errors.New("Attachment Reader was read by an earlier export")
 )
 
 type AddressType int
 type EncodingType int
 
 const (
-	AddressTo      AddressType = C.GMIME_RECIPIENT_TYPE_TO
-	AddressCC                  = C.GMIME_RECIPIENT_TYPE_CC
-	AddressFrom                = 100 + iota
-	AddressReplyTo             = 100 + iota
+	AddressTo           AddressType = C.GMIME_RECIPIENT_TYPE_TO
+	AddressCC                       = C.GMIME_RECIPIENT_TYPE_CC
+	AddressFrom                     = 100 + iota
+	AddressReplyTo                  = 100 + iota
+	AddressBCC                      = 100 + iota // envelope only, not written into exports
+	AddressSender                   = 100 + iota
+	AddressResentFrom               = 100 + iota
+	AddressResentSender             = 100 + iota
+	AddressResentTo                 = 100 + iota
+	AddressResentCC                 = 100 + iota
+	AddressResentBCC                = 100 + iota // envelope only, not written into exports
 )
 
 var (
-	EncodingDefault EncodingType = C.GMIME_CONTENT_ENCODING_DEFAULT
-	EncodingBase64  EncodingType = C.GMIME_CONTENT_ENCODING_BASE64
+	EncodingDefault         EncodingType = C.GMIME_CONTENT_ENCODING_DEFAULT
+	Encoding7bit            EncodingType = C.GMIME_CONTENT_ENCODING_7BIT
+	Encoding8bit            EncodingType = C.GMIME_CONTENT_ENCODING_8BIT
+	EncodingBinary          EncodingType = C.GMIME_CONTENT_ENCODING_BINARY
+	EncodingBase64          EncodingType = C.GMIME_CONTENT_ENCODING_BASE64
+	EncodingQuotedPrintable EncodingType = C.GMIME_CONTENT_ENCODING_QUOTEDPRINTABLE
 )
 
 type EmailAttachment struct {
@@ -59,27 +74,66 @@ type EmailAttachment struct {
 	Content        []byte
 	InputEncoding  *EncodingType
 	OutputEncoding *EncodingType
+
+	// Reader is used instead of Content when set, it is read while the message is written,
+	// so a message with Reader attachments can be exported only once, later exports
+	// fail with ErrReaderConsumed. Size limits how many bytes are read, 0 means read until io.EOF
+	Reader io.Reader
+	Size   int64
+	// readerConsumed is set once an export read from Reader
+	readerConsumed bool
+
+	// Headers are extra part headers, e.g. Content-Description, Content-Location,
+	// Content-Language or X-Attachment-Id. Headers generated from the fields above
+	// fail exports with *HeaderError
+	Headers []*EmailHeader
+	// Content-Disposition parameters of RFC 2183, written if not zero
+	DispositionSize  int64
+	CreationDate     time.Time
+	ModificationDate time.Time
 }
 
 type Message struct {
-	text      []byte
-	html      []byte
-	embeds    []*EmailAttachment
-	attaches  []*EmailAttachment
-	headers   []*EmailHeader
-	addresses []*EmailAddress
+	text        []byte
+	html        []byte
+	textOptions *BodyOptions
+	htmlOptions *BodyOptions
+	contentPart *Part
+	embeds      []*EmailAttachment
+	attaches    []*EmailAttachment
+	headers     []*EmailHeader
+	addresses   []*EmailAddress
+	dkimSigners []*DKIMSigner
+	smime       *SMIMEOptions
+	pgp         *PGPOptions
+	boundaries  BoundaryGenerator
+	addressMode AddressMode
+
+	fileNameEncoding FileNameEncoding
+
+	headerDefaults *HeaderDefaults
+
+	// html body transformations
+	autoText     bool
+	linkRewriter LinkRewriter
+	openTracker  OpenTracker
 }
 
 type EmailHeader struct {
-	Name  string
-	Value string
-	Raw   bool
+	Name    string
+	Value   string
+	Raw     bool          // value is written as is, same as FoldingRaw
+	Folding FoldingPolicy // used for message and part headers
 }
 
 type EmailAddress struct {
 	AddressType AddressType
 	Name        string
 	Address     string
+	// Group is name of RFC 5322 group the address belongs to, addresses of one type
+	// with the same Group are written as "Group: a, b;". Address may be empty
+	// for a group without members, e.g. "undisclosed-recipients:;"
+	Group string
 }
 
 func NewMessage() *Message {
@@ -88,10 +142,36 @@ func NewMessage() *Message {
 
 func (m *Message) SetText(body []byte) {
 	m.text = body
+	m.textOptions = nil
 }
 
 func (m *Message) SetHtml(body []byte) {
 	m.html = body
+	m.htmlOptions = nil
+}
+
+// SetTextWithOptions sets text/plain body written in options.Charset
+func (m *Message) SetTextWithOptions(body []byte, options *BodyOptions) {
+	m.text = body
+	m.textOptions = options
+}
+
+// SetHTMLWithOptions sets text/html body written in options.Charset
+func (m *Message) SetHTMLWithOptions(body []byte, options *BodyOptions) {
+	m.html = body
+	m.htmlOptions = options
+}
+
+// SetAutoText makes messages with html body and no text body get text/plain
+// alternative converted from html by HTMLToText
+func (m *Message) SetAutoText(enabled bool) {
+	m.autoText = enabled
+}
+
+// SetContentPart replaces text/html part built from SetText and SetHtml with p,
+// embeds and attachments are still added around it
+func (m *Message) SetContentPart(p *Part) {
+	m.contentPart = p
 }
 
 func (m *Message) Embed(a *EmailAttachment) {
@@ -116,13 +196,87 @@ func (m *Message) PrependHeader(h *EmailHeader) {
 	m.headers = append([]*EmailHeader{h}, m.headers...)
 }
 
+// SetHeader replaces all headers named h.Name, case-insensitive, with h
+// at the place of the first of them, h is appended if there are none
+func (m *Message) SetHeader(h *EmailHeader) {
+	headers := make([]*EmailHeader, 0, len(m.headers)+1)
+	replaced := false
+	for _, existing := range m.headers {
+		if !strings.EqualFold(existing.Name, h.Name) {
+			headers = append(headers, existing)
+		} else if !replaced {
+			headers = append(headers, h)
+			replaced = true
+		}
+	}
+	if !replaced {
+		headers = append(headers, h)
+	}
+	m.headers = headers
+}
+
+// RemoveHeader removes all headers named name, case-insensitive
+func (m *Message) RemoveHeader(name string) {
+	var headers []*EmailHeader
+	for _, h := range m.headers {
+		if !strings.EqualFold(h.Name, name) {
+			headers = append(headers, h)
+		}
+	}
+	m.headers = headers
+}
+
+// GetHeaders returns headers named name, case-insensitive, in the order they are written
+func (m *Message) GetHeaders(name string) []*EmailHeader {
+	var headers []*EmailHeader
+	for _, h := range m.headers {
+		if strings.EqualFold(h.Name, name) {
+			headers = append(headers, h)
+		}
+	}
+	return headers
+}
+
 func (m *Message) AddAddress(a *EmailAddress) {
 	m.addresses = append(m.addresses, a)
 }
 
+// EnvelopeRecipients returns addresses the message is delivered to: To, CC and BCC,
+// or Resent-To, Resent-CC and Resent-BCC if the message is resent
+func (m *Message) EnvelopeRecipients() []string {
+	types := []AddressType{AddressTo, AddressCC, AddressBCC}
+	for _, a := range m.addresses {
+		if a.AddressType == AddressResentTo || a.AddressType == AddressResentCC || a.AddressType == AddressResentBCC {
+			types = []AddressType{AddressResentTo, AddressResentCC, AddressResentBCC}
+			break
+		}
+	}
+	var recipients []string
+	seen := map[string]bool{}
+	for _, a := range m.addresses {
+		if a.Address == "" || seen[strings.ToLower(a.Address)] {
+			continue
+		}
+		for _, t := range types {
+			if a.AddressType == t {
+				seen[strings.ToLower(a.Address)] = true
+				recipients = append(recipients, a.Address)
+				break
+			}
+		}
+	}
+	return recipients
+}
+
+// AddDKIMSigner makes exports carry a DKIM-Signature header by s,
+// with several signers their headers go in the order signers were added
+func (m *Message) AddDKIMSigner(s *DKIMSigner) {
+	m.dkimSigners = append(m.dkimSigners, s)
+}
+
 // ExportSplit
 func (m *Message) ExportMIMEMessage() (*MIMEMessage, error) {
-	message, err := m.gmimize() // needs unref
+	message, readers, err := m.gmimize(m.boundaryGenerator()) // needs unref
 	if err != nil {
 		return nil, err
 	}
@@ -140,11 +294,29 @@ func (m *Message) ExportMIMEMessage() (*MIMEMessage, error) {
 	C.g_mime_stream_filter_add((*C.GMimeStreamFilter)(unsafe.Pointer(stream)), filterCRLF)
 
 	nWritten := C.g_mime_object_write_to_stream((*C.GMimeObject)(unsafe.Pointer(message)), stream)
+	if err := readError(readers); err != nil {
+		return nil, err
+	}
 	if nWritten <= 0 {
 		return nil, ErrWrite
 	}
 
 	byteArray := C.g_mime_stream_mem_get_byte_array((*C.GMimeStreamMem)(unsafe.Pointer(rawStream)))
+	encodedHeaders := encodedHeadersFromGmime(anyToGMimeObject(unsafe.Pointer(message)))
+	if len(m.dkimSigners) > 0 {
+		h := reflect.SliceHeader{
+			Data: uintptr(unsafe.Pointer(byteArray.data)),
+			Len:  (int)(byteArray.len),
+			Cap:  (int)(byteArray.len),
+		}
+		fields, err := dkimSignatures(m.dkimSigners, *(*[]byte)(unsafe.Pointer(&h)))
+		if err != nil {
+			return nil, err
+		}
+		signatures := []byte(strings.Join(fields, ""))
+		C.g_byte_array_prepend(byteArray, (*C.guint8)(unsafe.Pointer(&signatures[0])), C.guint(len(signatures)))
+		encodedHeaders = append(dkimEncodedHeaders(fields), encodedHeaders...)
+	}
 	nWritten = C.ssize_t(byteArray.len)
 	C.g_mime_stream_mem_set_owner((*C.GMimeStreamMem)(unsafe.Pointer(rawStream)), C.FALSE) // tell stream that we own GByteArray
 	h := reflect.SliceHeader{
@@ -156,14 +328,14 @@ func (m *Message) ExportMIMEMessage() (*MIMEMessage, error) {
 	C.g_byte_array_free(byteArray, C.FALSE) // free GByteArray structure, but keep byteArray->data allocated, we will free it in MIMEMessage.Close()
 
 	mimeMessage := &MIMEMessage{
-		EncodedHeaders: encodedHeadersFromGmime(anyToGMimeObject(unsafe.Pointer(message))),
+		EncodedHeaders: encodedHeaders,
 		Body:           s,
 	}
 	return mimeMessage, nil
 }
 
 func (m *Message) Export() ([]byte, error) {
-	message, err := m.gmimize() // need unref
+	message, readers, err := m.gmimize(m.boundaryGenerator()) // need unref
 	if err != nil {
 		return nil, err
 	}
@@ -172,16 +344,101 @@ func (m *Message) Export() ([]byte, error) {
 	stream := C.g_mime_stream_mem_new() // need unref
 	defer C.g_object_unref(stream)      // unref
 	nWritten := C.g_mime_object_write_to_stream((*C.GMimeObject)(unsafe.Pointer(message)), stream)
+	if err := readError(readers); err != nil {
+		return nil, err
+	}
 	if nWritten <= 0 {
 		return nil, ErrWrite
 	}
 	// byteArray is owned by stream and will be freed with it
 	byteArray := C.g_mime_stream_mem_get_byte_array((*C.GMimeStreamMem)(unsafe.Pointer(stream)))
-	return C.GoBytes(unsafe.Pointer(byteArray.data), (C.int)(nWritten)), nil
+	data := C.GoBytes(unsafe.Pointer(byteArray.data), (C.int)(nWritten))
+	if len(m.dkimSigners) > 0 {
+		// signatures are computed over CRLF form, the header itself follows Export line endings
+		fields, err := dkimSignatures(m.dkimSigners, toCRLF(data))
+		if err != nil {
+			return nil, err
+		}
+		signatures := strings.Replace(strings.Join(fields, ""), "\r\n", "\n", -1)
+		data = append([]byte(signatures), data...)
+	}
+	return data, nil
+}
+
+// WriteTo writes the message with CRLF line endings straight to w, without building it in memory first.
+// It implements io.WriterTo, so the message can be copied into SMTP DATA or a file
+func (m *Message) WriteTo(w io.Writer) (int64, error) {
+	message, readers, err := m.gmimize(m.boundaryGenerator()) // need unref
+	if err != nil {
+		return 0, err
+	}
+	defer C.g_object_unref(message) // unref
+
+	var n int64
+	if len(m.dkimSigners) > 0 {
+		// DKIM-Signature goes before the message, so the message is written twice:
+		// to compute the signatures and then to w
+		if len(readers) > 0 {
+			return 0, ErrDKIMReader
+		}
+		dkim := newDKIMWriter(m.dkimSigners)
+		if _, err := writeCRLF(message, readers, dkim); err != nil {
+			return 0, err
+		}
+		fields, err := dkim.fields()
+		if err != nil {
+			return 0, err
+		}
+		nFields, err := io.WriteString(w, strings.Join(fields, ""))
+		n += int64(nFields)
+		if err != nil {
+			return n, err
+		}
+	}
+	nMessage, err := writeCRLF(message, readers, w)
+	return n + nMessage, err
+}
+
+// writeCRLF writes message through CRLF filter to w, readers are streams of Reader attachments of message
+func writeCRLF(message *C.GMimeMessage, readers []*goStream, w io.Writer) (int64, error) {
+	output := &goStream{w: w}
+	rawStream := newGoStream(output)  // need unref
+	defer C.g_object_unref(rawStream) // unref
+
+	stream := C.g_mime_stream_filter_new(rawStream)
+	defer C.g_object_unref(stream) // unref
+
+	filterCRLF := C.g_mime_filter_crlf_new(C.TRUE, C.FALSE)
+	defer C.g_object_unref(filterCRLF) // unref
+
+	C.g_mime_stream_filter_add((*C.GMimeStreamFilter)(unsafe.Pointer(stream)), filterCRLF)
+
+	nWritten := C.g_mime_object_write_to_stream((*C.GMimeObject)(unsafe.Pointer(message)), stream)
+	C.g_mime_stream_flush(stream) // push out data held by the filter
+	if output.err != nil {
+		return output.n, output.err
+	}
+	if err := readError(readers); err != nil {
+		return output.n, err
+	}
+	if nWritten <= 0 {
+		return output.n, ErrWrite
+	}
+	return output.n, nil
+}
+
+// readError returns the first error returned by EmailAttachment.Reader of readers while the message was written
+func readError(readers []*goStream) error {
+	for _, r := range readers {
+		if r.err != nil {
+			return r.err
+		}
+	}
+	return nil
 }
 
 func (m *Message) Print() error {
-	message, err := m.gmimize()
+	message, _, err := m.gmimize(m.boundaryGenerator())
 	if err != nil {
 		return err
 	}
@@ -193,8 +450,38 @@ func (m *Message) Print() error {
 	return nil
 }
 
-// returns *GMimeMessage, need unref
-func (m *Message) gmimize() (*C.GMimeMessage, error) {
+// bodies returns text and html bodies as they are exported: with rewritten links,
+// open tracking image and text converted from html if these are on.
+// Tracked and untracked exports share this path
+func (m *Message) bodies() (text, html []byte, textOptions *BodyOptions) {
+	text, html, textOptions = m.text, m.html, m.textOptions
+	if m.linkRewriter != nil && len(html) > 0 {
+		var urls map[string]string
+		html, urls = rewriteLinks(html, m.linkRewriter, listUnsubscribeURLs(m.headers))
+		text = rewriteTextLinks(text, urls)
+	}
+	if m.openTracker != nil && len(html) > 0 {
+		html = injectOpenPixel(html, m.openTracker(m.firstAddress(AddressTo)))
+	}
+	if m.autoText && len(text) == 0 && len(html) > 0 {
+		// tokenizer decodes entities to UTF-8, so html is converted to UTF-8 first and text goes out as utf-8.
+		// Without a converter the bytes stay in the charset of html
+		source, charset := html, ""
+		if m.htmlOptions != nil {
+			var ok bool
+			if source, ok = bodyToUTF8(html, m.htmlOptions.Charset); !ok {
+				charset = m.htmlOptions.Charset
+			}
+		}
+		text = HTMLToText(source)
+		textOptions = &BodyOptions{Charset: charset}
+	}
+	return text, html, textOptions
+}
+
+// returns *GMimeMessage, need unref, and streams of Reader attachments which are read when the message is written.
+// Streams belong to this call, so exports of one Message may run concurrently
+func (m *Message) gmimize(boundaries BoundaryGenerator) (*C.GMimeMessage, []*goStream, error) {
 	// - mixed
 	//     - related
 	//         - alternative
@@ -204,36 +491,94 @@ func (m *Message) gmimize() (*C.GMimeMessage, error) {
 	//         - embedded image 2
 	//     - Attachment 1
 	//     - Attachment 2
+	if err := validateHeaders(m.headers); err != nil {
+		return nil, nil, err
+	}
+	if err := validateAddresses(m.addresses); err != nil {
+		return nil, nil, err
+	}
+	if err := validateBodyOptions(m.textOptions, m.htmlOptions); err != nil {
+		return nil, nil, err
+	}
+	for _, attaches := range [][]*EmailAttachment{m.embeds, m.attaches} {
+		for _, a := range attaches {
+			if err := validateAttachment(a); err != nil {
+				return nil, nil, err
+			}
+			if a.Reader != nil && a.readerConsumed {
+				return nil, nil, ErrReaderConsumed
+			}
+		}
+	}
+
 	var contentPart *C.GMimeObject
-	contentPart, err := textHTMLPart(m.text, m.html) // need unref
+	var readers []*goStream
+	var err error
+	if m.contentPart != nil {
+		contentPart, readers, err = m.contentPart.gmimize(boundaries) // need unref
+	} else {
+		text, html, textOptions := m.bodies()
+		contentPart, err = textHTMLPart(text, html, textOptions, m.htmlOptions, boundaries) // need unref
+	}
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer C.g_object_unref(contentPart) // unref
 
 	if len(m.embeds) > 0 {
 		// if there are embeds - add "related" part
-		relatedPart := newMultiPartWithSubtype(cStringRelated) // need unref
-		defer C.g_object_unref(relatedPart)                    // unref
+		relatedPart := newMultiPartWithSubtype(cStringRelated, boundaries) // need unref
+		defer C.g_object_unref(relatedPart)                                // unref
 		C.g_mime_multipart_add(relatedPart, contentPart)
 		contentPart = anyToGMimeObject(unsafe.Pointer(relatedPart))
-		addAttachments(relatedPart, m.embeds)
+		readers = append(readers, addAttachments(relatedPart, m.embeds, m.fileNameEncoding)...)
 	}
 
 	if len(m.attaches) > 0 {
 		// if there are attaches - add "mixed" part
-		mixedPart := newMultiPartWithSubtype(cStringMixed) // need unref
-		defer C.g_object_unref(mixedPart)                  // unref
+		mixedPart := newMultiPartWithSubtype(cStringMixed, boundaries) // need unref
+		defer C.g_object_unref(mixedPart)                              // unref
 		C.g_mime_multipart_add(mixedPart, contentPart)
 		contentPart = anyToGMimeObject(unsafe.Pointer(mixedPart))
-		addAttachments(mixedPart, m.attaches)
+		readers = append(readers, addAttachments(mixedPart, m.attaches, m.fileNameEncoding)...)
+	}
+
+	// signing and encryption cover the whole content, message headers stay outside
+	switch {
+	case m.smime != nil && m.pgp != nil:
+		return nil, nil, ErrSMIMEAndPGP
+	case m.smime != nil:
+		smimePart, err := m.smime.wrap(contentPart, boundaries) // need unref
+		if err != nil {
+			return nil, nil, err
+		}
+		defer C.g_object_unref(smimePart) // unref
+		contentPart = smimePart
+	case m.pgp != nil:
+		pgpPart, err := m.pgp.wrap(contentPart, boundaries) // need unref
+		if err != nil {
+			return nil, nil, err
+		}
+		defer C.g_object_unref(pgpPart) // unref
+		contentPart = pgpPart
+	}
+
+	addresses := m.addresses
+	if m.addressMode == AddressModeASCII {
+		if addresses, err = asciiAddresses(addresses); err != nil {
+			return nil, nil, err
+		}
 	}
 
 	message := C.g_mime_message_new(C.TRUE) // this message is returned, caller to unref
 
-	injectHeaders(anyToGMimeObject(unsafe.Pointer(message)), m.headers, m.addresses)
+	injectHeaders(anyToGMimeObject(unsafe.Pointer(message)), m.headers, addresses, m.addressMode == AddressModeSMTPUTF8)
+	if err := injectHeaderDefaults(message, m.headerDefaults, m.headers, addresses); err != nil {
+		C.g_object_unref(message)
+		return nil, nil, err
+	}
 
 	C.g_mime_message_set_mime_part(message, contentPart)
 
-	return message, nil
+	return message, readers, nil
 }
@@ -0,0 +1,121 @@
+package gmime
+
+/*
+#cgo pkg-config: gmime-2.6
+#include <stdlib.h>
+#include <gmime/gmime.h>
+
+// sign_prepare switches parts to 7bit safe encoding, so relays can't change signed content,
+// same as GMime does for g_mime_multipart_signed_sign
+static void sign_prepare(GMimeObject *mime_part) {
+	GMimeContentEncoding encoding;
+	int i, n;
+
+	if (GMIME_IS_MULTIPART(mime_part)) {
+		if (GMIME_IS_MULTIPART_SIGNED(mime_part) || GMIME_IS_MULTIPART_ENCRYPTED(mime_part)) {
+			// must not modify these parts as they must be treated as opaque
+			return;
+		}
+		n = g_mime_multipart_get_count((GMimeMultipart *)mime_part);
+		for (i = 0; i < n; i++) {
+			sign_prepare(g_mime_multipart_get_part((GMimeMultipart *)mime_part, i));
+		}
+	} else if (GMIME_IS_MESSAGE_PART(mime_part)) {
+		GMimeMessage *message = g_mime_message_part_get_message((GMimeMessagePart *)mime_part);
+		if (message != NULL) {
+			sign_prepare(g_mime_message_get_mime_part(message));
+		}
+	} else if (GMIME_IS_PART(mime_part)) {
+		encoding = g_mime_part_get_content_encoding((GMimePart *)mime_part);
+		if (encoding != GMIME_CONTENT_ENCODING_BASE64) {
+			g_mime_part_set_content_encoding((GMimePart *)mime_part, GMIME_CONTENT_ENCODING_QUOTEDPRINTABLE);
+		}
+	}
+}
+*/
+import "C"
+import "unsafe"
+
+// cryptoContentBytes returns obj as it is written into multipart/signed or encrypted by a crypto layer.
+// The result has LF line endings, signatures are computed over its CRLF form
+func cryptoContentBytes(obj *C.GMimeObject, signing bool) []byte {
+	if signing {
+		// sign_prepare changes encodings of parts, it works on a copy so obj stays as the caller built it
+		if copied := objectFromBytes(cryptoContentBytes(obj, false)); copied != nil { // needs unref
+			defer C.g_object_unref(copied) // unref
+			obj = copied
+		}
+		C.sign_prepare(obj)
+	}
+
+	rawStream := C.g_mime_stream_mem_new() // needs unref
+	defer C.g_object_unref(rawStream)      // unref
+
+	stream := C.g_mime_stream_filter_new(rawStream) // needs unref
+	defer C.g_object_unref(stream)                  // unref
+
+	if signing {
+		// escape "From " lines which could be mangled on the way, RFC 3156 section 3
+		filterFrom := C.g_mime_filter_from_new(C.GMIME_FILTER_FROM_MODE_ARMOR) // needs unref
+		C.g_mime_stream_filter_add((*C.GMimeStreamFilter)(unsafe.Pointer(stream)), filterFrom)
+		C.g_object_unref(filterFrom) // unref
+	}
+
+	C.g_mime_object_write_to_stream(obj, stream)
+	C.g_mime_stream_flush(stream)
+
+	// byteArray is owned by rawStream and will be freed with it
+	byteArray := C.g_mime_stream_mem_get_byte_array((*C.GMimeStreamMem)(unsafe.Pointer(rawStream)))
+	return C.GoBytes(unsafe.Pointer(byteArray.data), (C.int)(byteArray.len))
+}
+
+// returns GMimeObject parsed from MIME entity, so it is written exactly as data
+// caller responsible for unref
+func objectFromBytes(data []byte) *C.GMimeObject {
+	if len(data) == 0 {
+		return nil
+	}
+	stream := C.g_mime_stream_mem_new_with_buffer((*C.char)(unsafe.Pointer(&data[0])), C.size_t(len(data))) // needs unref
+	defer C.g_object_unref(stream)                                                                          // unref
+
+	parser := C.g_mime_parser_new_with_stream(stream) // needs unref
+	defer C.g_object_unref(parser)                    // unref
+
+	return C.g_mime_parser_construct_part(parser) // caller to unref
+}
+
+// signedMultipart returns multipart/signed (RFC 1847) of content and detached signature,
+// content should come from cryptoContentBytes
+// caller responsible for unref
+func signedMultipart(content []byte, signature *Part, protocol, micalg string, boundaries BoundaryGenerator) (*C.GMimeObject, error) {
+	contentObj := objectFromBytes(content) // needs unref
+	if contentObj == nil {
+		return nil, ErrParse
+	}
+	defer C.g_object_unref(contentObj) // unref
+
+	signatureObj, _, err := signature.gmimize(boundaries) // needs unref
+	if err != nil {
+		return nil, err
+	}
+	defer C.g_object_unref(signatureObj) // unref
+
+	subtype := C.CString("signed")                            // needs free
+	multipart := newMultiPartWithSubtype(subtype, boundaries) // caller to unref
+	C.free(unsafe.Pointer(subtype))                           // free
+	obj := anyToGMimeObject(unsafe.Pointer(multipart))
+	setContentTypeParameter(obj, "protocol", protocol)
+	setContentTypeParameter(obj, "micalg", micalg)
+
+	C.g_mime_multipart_add(multipart, contentObj)
+	C.g_mime_multipart_add(multipart, signatureObj)
+	return obj, nil
+}
+
+func setContentTypeParameter(obj *C.GMimeObject, name, value string) {
+	cName := C.CString(name)   // needs free
+	cValue := C.CString(value) // needs free
+	C.g_mime_object_set_content_type_parameter(obj, cName, cValue)
+	C.free(unsafe.Pointer(cName))  // free
+	C.free(unsafe.Pointer(cValue)) // free
+}
@@ -0,0 +1,391 @@
+package gmime
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrDKIMKey    = errors.New("DKIM signer key should be RSA or Ed25519")
+	ErrDKIMFrom   = errors.New("DKIM signed message has no From header")
+	ErrDKIMReader = errors.New("DKIM signed message can't be streamed with Reader attachments")
+)
+
+type Canonicalization string
+
+const (
+	CanonicalizationSimple  Canonicalization = "simple"
+	CanonicalizationRelaxed Canonicalization = "relaxed"
+)
+
+// DefaultDKIMHeaders are signed when DKIMSigner.Headers is empty
+var DefaultDKIMHeaders = []string{
+	"From", "Reply-To", "Subject", "Date", "To", "Cc", "Message-Id",
+	"In-Reply-To", "References", "MIME-Version", "Content-Type", "Content-Transfer-Encoding",
+	"List-Id", "List-Unsubscribe", "List-Unsubscribe-Post",
+}
+
+// DKIMSigner adds DKIM-Signature header (RFC 6376) to exported messages, see Message.AddDKIMSigner.
+// The signature is computed over the message with CRLF line endings, exactly as ExportMIMEMessage and WriteTo produce it
+type DKIMSigner struct {
+	Domain   string        // d= tag
+	Selector string        // s= tag
+	Identity string        // optional i= tag
+	Signer   crypto.Signer // *rsa.PrivateKey for rsa-sha256 or ed25519.PrivateKey for ed25519-sha256
+
+	HeaderCanonicalization Canonicalization // relaxed if empty
+	BodyCanonicalization   Canonicalization // relaxed if empty
+
+	// Headers lists header names to sign, every occurrence of a listed header is signed.
+	// DefaultDKIMHeaders if empty, From is always signed
+	Headers []string
+
+	// BodyLength adds l= tag with the length of the canonicalized body
+	BodyLength bool
+}
+
+func (s *DKIMSigner) algorithm() (string, crypto.Hash, error) {
+	if s.Signer == nil {
+		return "", 0, ErrDKIMKey
+	}
+	switch s.Signer.Public().(type) {
+	case *rsa.PublicKey:
+		return "rsa-sha256", crypto.SHA256, nil
+	case ed25519.PublicKey:
+		// RFC 8463, PureEdDSA over SHA-256 hash of the data
+		return "ed25519-sha256", crypto.Hash(0), nil
+	default:
+		return "", 0, ErrDKIMKey
+	}
+}
+
+func (s *DKIMSigner) canonicalization() (Canonicalization, Canonicalization) {
+	headerCanonicalization, bodyCanonicalization := s.HeaderCanonicalization, s.BodyCanonicalization
+	if headerCanonicalization == "" {
+		headerCanonicalization = CanonicalizationRelaxed
+	}
+	if bodyCanonicalization == "" {
+		bodyCanonicalization = CanonicalizationRelaxed
+	}
+	return headerCanonicalization, bodyCanonicalization
+}
+
+// sign returns DKIM-Signature header field with CRLF line endings
+func (s *DKIMSigner) sign(header []byte, body *dkimBody) (string, error) {
+	algorithm, hashType, err := s.algorithm()
+	if err != nil {
+		return "", err
+	}
+	headerCanonicalization, bodyCanonicalization := s.canonicalization()
+
+	fields := parseHeaderFields(header)
+	names := s.Headers
+	if len(names) == 0 {
+		names = DefaultDKIMHeaders
+	}
+	var signedNames []string
+	seen := map[string]bool{}
+	for _, name := range append([]string{"From"}, names...) {
+		key := strings.ToLower(name)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		for _, f := range fields {
+			if strings.EqualFold(f.name, name) {
+				signedNames = append(signedNames, f.name)
+			}
+		}
+	}
+	if len(signedNames) == 0 || !strings.EqualFold(signedNames[0], "From") {
+		return "", ErrDKIMFrom
+	}
+
+	tags := [][2]string{
+		{"v", "1"},
+		{"a", algorithm},
+		{"c", string(headerCanonicalization) + "/" + string(bodyCanonicalization)},
+		{"d", s.Domain},
+		{"s", s.Selector},
+	}
+	if s.Identity != "" {
+		tags = append(tags, [2]string{"i", s.Identity})
+	}
+	if s.BodyLength {
+		tags = append(tags, [2]string{"l", strconv.FormatInt(body.length, 10)})
+	}
+	tags = append(tags,
+		[2]string{"h", strings.Join(signedNames, ":")},
+		[2]string{"bh", base64.StdEncoding.EncodeToString(body.sum())},
+	)
+
+	// fold between tags, b= goes last on its own line so its value can be folded too
+	field := "DKIM-Signature:"
+	lineLength := len(field)
+	for _, tag := range tags {
+		t := " " + tag[0] + "=" + tag[1] + ";"
+		if lineLength+len(t) > 78 {
+			field += "\r\n\t"
+			t = t[1:]
+			lineLength = 8
+		}
+		field += t
+		lineLength += len(t)
+	}
+	field += "\r\n\tb="
+
+	h := sha256.New()
+	for _, f := range selectHeaderFields(fields, signedNames) {
+		io.WriteString(h, canonicalHeader(f.raw, headerCanonicalization))
+	}
+	io.WriteString(h, strings.TrimSuffix(canonicalHeader(field, headerCanonicalization), "\r\n"))
+
+	signature, err := s.Signer.Sign(rand.Reader, h.Sum(nil), hashType)
+	if err != nil {
+		return "", err
+	}
+	b := base64.StdEncoding.EncodeToString(signature)
+	for len(b) > 72 {
+		field += b[:72] + "\r\n\t"
+		b = b[72:]
+	}
+	return field + b + "\r\n", nil
+}
+
+// dkimWriter takes a message with CRLF line endings and computes DKIM-Signature header fields of all signers
+type dkimWriter struct {
+	signers []*DKIMSigner
+	header  []byte
+	inBody  bool
+	bodies  []*dkimBody
+}
+
+func newDKIMWriter(signers []*DKIMSigner) *dkimWriter {
+	w := &dkimWriter{signers: signers}
+	for _, s := range signers {
+		_, bodyCanonicalization := s.canonicalization()
+		w.bodies = append(w.bodies, newDKIMBody(bodyCanonicalization, -1))
+	}
+	return w
+}
+
+func (w *dkimWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if !w.inBody {
+		start := len(w.header) - 3
+		if start < 0 {
+			start = 0
+		}
+		w.header = append(w.header, p...)
+		i := bytes.Index(w.header[start:], []byte("\r\n\r\n"))
+		if i < 0 {
+			return n, nil
+		}
+		i += start + 2 // header block keeps CRLF of the last field
+		p = w.header[i+2:]
+		w.header = w.header[:i]
+		w.inBody = true
+	}
+	for _, b := range w.bodies {
+		b.Write(p)
+	}
+	return n, nil
+}
+
+// fields returns DKIM-Signature header fields with CRLF line endings in signers order
+func (w *dkimWriter) fields() ([]string, error) {
+	var fields []string
+	for i, s := range w.signers {
+		w.bodies[i].Close()
+		field, err := s.sign(w.header, w.bodies[i])
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// dkimSignatures returns DKIM-Signature header fields for message with CRLF line endings
+func dkimSignatures(signers []*DKIMSigner, message []byte) ([]string, error) {
+	w := newDKIMWriter(signers)
+	w.Write(message)
+	return w.fields()
+}
+
+// dkimBody canonicalizes body (RFC 6376 3.4.3, 3.4.4) written in chunks and hashes at most limit bytes of it
+type dkimBody struct {
+	canonicalization Canonicalization
+	hash             hash.Hash
+	limit            int64 // -1 for no limit
+	length           int64 // length of the whole canonicalized body
+	line             []byte
+	emptyLines       int
+}
+
+func newDKIMBody(canonicalization Canonicalization, limit int64) *dkimBody {
+	return &dkimBody{
+		canonicalization: canonicalization,
+		hash:             sha256.New(),
+		limit:            limit,
+	}
+}
+
+func (b *dkimBody) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			b.line = append(b.line, p...)
+			break
+		}
+		b.line = append(b.line, p[:i]...)
+		b.endLine()
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+func (b *dkimBody) endLine() {
+	line := bytes.TrimSuffix(b.line, []byte("\r"))
+	if b.canonicalization == CanonicalizationRelaxed {
+		line = relaxWhitespace(line)
+	}
+	if len(line) == 0 {
+		// trailing empty lines are dropped, so hold them back until a non-empty line comes
+		b.emptyLines++
+	} else {
+		for ; b.emptyLines > 0; b.emptyLines-- {
+			b.emit([]byte("\r\n"))
+		}
+		b.emit(line)
+		b.emit([]byte("\r\n"))
+	}
+	b.line = b.line[:0]
+}
+
+func (b *dkimBody) emit(p []byte) {
+	if b.limit >= 0 && b.length < b.limit {
+		if left := b.limit - b.length; int64(len(p)) > left {
+			b.hash.Write(p[:left])
+		} else {
+			b.hash.Write(p)
+		}
+	} else if b.limit < 0 {
+		b.hash.Write(p)
+	}
+	b.length += int64(len(p))
+}
+
+// Close flushes the last line without CRLF
+func (b *dkimBody) Close() error {
+	if len(b.line) > 0 {
+		b.endLine()
+	}
+	if b.length == 0 && b.canonicalization == CanonicalizationSimple {
+		// empty body is a single CRLF in simple canonicalization
+		b.emit([]byte("\r\n"))
+	}
+	return nil
+}
+
+func (b *dkimBody) sum() []byte {
+	return b.hash.Sum(nil)
+}
+
+// relaxWhitespace reduces runs of whitespace to a single space and removes trailing whitespace
+func relaxWhitespace(line []byte) []byte {
+	relaxed := make([]byte, 0, len(line))
+	space := false
+	for _, c := range line {
+		if c == ' ' || c == '\t' {
+			space = true
+			continue
+		}
+		if space {
+			relaxed = append(relaxed, ' ')
+			space = false
+		}
+		relaxed = append(relaxed, c)
+	}
+	return relaxed
+}
+
+type headerField struct {
+	name string
+	raw  string // field with continuation lines and trailing CRLF
+}
+
+// parseHeaderFields splits header block with CRLF line endings into fields
+func parseHeaderFields(header []byte) []headerField {
+	var fields []headerField
+	for _, line := range strings.SplitAfter(string(header), "\r\n") {
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(fields) > 0 {
+			fields[len(fields)-1].raw += line
+			continue
+		}
+		name := line
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			name = line[:i]
+		}
+		fields = append(fields, headerField{name: strings.TrimRight(name, " \t"), raw: line})
+	}
+	return fields
+}
+
+// selectHeaderFields picks fields listed in h= tag, repeated names select instances from the bottom up
+func selectHeaderFields(fields []headerField, names []string) []headerField {
+	var selected []headerField
+	used := make([]bool, len(fields))
+	for _, name := range names {
+		for i := len(fields) - 1; i >= 0; i-- {
+			if !used[i] && strings.EqualFold(fields[i].name, strings.TrimSpace(name)) {
+				used[i] = true
+				selected = append(selected, fields[i])
+				break
+			}
+		}
+	}
+	return selected
+}
+
+// canonicalHeader returns header field canonicalized according to RFC 6376 3.4.1, 3.4.2
+func canonicalHeader(raw string, canonicalization Canonicalization) string {
+	if canonicalization == CanonicalizationSimple {
+		return raw
+	}
+	i := strings.IndexByte(raw, ':')
+	if i < 0 {
+		return raw
+	}
+	name := strings.ToLower(strings.TrimRight(raw[:i], " \t"))
+	value := strings.NewReplacer("\r\n", "", "\n", "").Replace(raw[i+1:])
+	value = strings.TrimSpace(string(relaxWhitespace([]byte(value))))
+	return name + ":" + value + "\r\n"
+}
+
+// toCRLF converts LF line endings of Export output the same way as GMime CRLF filter does,
+// LF which already follows CR is kept as is
+func toCRLF(message []byte) []byte {
+	converted := make([]byte, 0, len(message)+bytes.Count(message, []byte("\n")))
+	for i, c := range message {
+		if c == '\n' && (i == 0 || message[i-1] != '\r') {
+			converted = append(converted, '\r')
+		}
+		converted = append(converted, c)
+	}
+	return converted
+}
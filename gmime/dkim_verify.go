@@ -0,0 +1,300 @@
+package gmime
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type DKIMStatus string
+
+const (
+	DKIMPass    DKIMStatus = "pass"
+	DKIMFail    DKIMStatus = "fail"
+	DKIMNeutral DKIMStatus = "neutral" // signature could not be evaluated
+)
+
+// DKIMKeyLookup returns TXT records of a DNS name, name is <selector>._domainkey.<domain>
+type DKIMKeyLookup interface {
+	LookupTXT(name string) ([]string, error)
+}
+
+// DNSKeyLookup looks DKIM keys up in DNS
+type DNSKeyLookup struct{}
+
+func (DNSKeyLookup) LookupTXT(name string) ([]string, error) {
+	return net.LookupTXT(name)
+}
+
+// DKIMResult is the result of verification of one DKIM-Signature header
+type DKIMResult struct {
+	Domain   string
+	Selector string
+	Status   DKIMStatus
+	Reason   string // why the signature failed or was neutral
+}
+
+// VerifyDKIM verifies every DKIM-Signature header of m, results go in the order of headers.
+// m should come from ParseMessage or ParseBytes, the original message is used
+func VerifyDKIM(m *ParsedMessage, lookup DKIMKeyLookup) []*DKIMResult {
+	header, body := splitMessage(normalizeCRLF(m.Raw))
+	fields := parseHeaderFields(header)
+
+	var results []*DKIMResult
+	for _, f := range fields {
+		if strings.EqualFold(f.name, "DKIM-Signature") {
+			results = append(results, verifyDKIMSignature(f, fields, body, lookup))
+		}
+	}
+	return results
+}
+
+func verifyDKIMSignature(signature headerField, fields []headerField, body []byte, lookup DKIMKeyLookup) *DKIMResult {
+	tags := parseDKIMTags(signature.raw[strings.IndexByte(signature.raw, ':')+1:])
+	result := &DKIMResult{Domain: tags["d"], Selector: tags["s"]}
+	neutral := func(reason string) *DKIMResult {
+		result.Status, result.Reason = DKIMNeutral, reason
+		return result
+	}
+	fail := func(reason string) *DKIMResult {
+		result.Status, result.Reason = DKIMFail, reason
+		return result
+	}
+
+	for _, tag := range []string{"v", "a", "b", "bh", "d", "h", "s"} {
+		if _, ok := tags[tag]; !ok {
+			return neutral("missing " + tag + "= tag")
+		}
+	}
+	if tags["v"] != "1" {
+		return neutral("unsupported version " + tags["v"])
+	}
+	if i, ok := tags["i"]; ok {
+		// RFC 6376 3.5, identity is in the signing domain or its subdomain
+		_, domain := splitAddress(i)
+		domain, signingDomain := strings.ToLower(domain), strings.ToLower(tags["d"])
+		if domain != signingDomain && !strings.HasSuffix(domain, "."+signingDomain) {
+			return neutral("i= domain is not d= domain or its subdomain")
+		}
+	}
+	var names []string
+	signsFrom := false
+	for _, name := range strings.Split(tags["h"], ":") {
+		name = strings.TrimSpace(name)
+		signsFrom = signsFrom || strings.EqualFold(name, "From")
+		names = append(names, name)
+	}
+	if !signsFrom {
+		return neutral("From header is not signed")
+	}
+
+	headerCanonicalization, bodyCanonicalization := CanonicalizationSimple, CanonicalizationSimple
+	if c, ok := tags["c"]; ok {
+		split := strings.SplitN(c, "/", 2)
+		headerCanonicalization = Canonicalization(split[0])
+		if len(split) == 2 {
+			bodyCanonicalization = Canonicalization(split[1])
+		}
+	}
+	for _, c := range []Canonicalization{headerCanonicalization, bodyCanonicalization} {
+		if c != CanonicalizationSimple && c != CanonicalizationRelaxed {
+			return neutral("unsupported canonicalization " + string(c))
+		}
+	}
+
+	limit := int64(-1)
+	if l, ok := tags["l"]; ok {
+		var err error
+		if limit, err = strconv.ParseInt(l, 10, 64); err != nil || limit < 0 {
+			return neutral("malformed l= tag")
+		}
+	}
+	if x, ok := tags["x"]; ok {
+		expiration, err := strconv.ParseInt(x, 10, 64)
+		if err != nil {
+			return neutral("malformed x= tag")
+		}
+		if time.Now().Unix() > expiration {
+			return fail("signature expired")
+		}
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil {
+		return neutral("malformed b= tag")
+	}
+	bodyHash, err := base64.StdEncoding.DecodeString(tags["bh"])
+	if err != nil {
+		return neutral("malformed bh= tag")
+	}
+
+	key, err := lookupDKIMKey(lookup, tags["s"]+"._domainkey."+tags["d"])
+	if err != nil {
+		return neutral(err.Error())
+	}
+	if key == nil {
+		return fail("key revoked")
+	}
+
+	bodyCanonical := newDKIMBody(bodyCanonicalization, limit)
+	bodyCanonical.Write(body)
+	bodyCanonical.Close()
+	if limit > bodyCanonical.length {
+		return fail("l= tag is longer than the body")
+	}
+	if !bytes.Equal(bodyCanonical.sum(), bodyHash) {
+		return fail("body hash mismatch")
+	}
+
+	h := sha256.New()
+	for _, f := range selectHeaderFields(fields, names) {
+		io.WriteString(h, canonicalHeader(f.raw, headerCanonicalization))
+	}
+	io.WriteString(h, strings.TrimSuffix(canonicalHeader(stripDKIMSignatureValue(signature.raw), headerCanonicalization), "\r\n"))
+	digest := h.Sum(nil)
+
+	switch tags["a"] {
+	case "rsa-sha256":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return neutral("key type doesn't match a= tag")
+		}
+		if rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest, sig) != nil {
+			return fail("signature mismatch")
+		}
+	case "ed25519-sha256":
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return neutral("key type doesn't match a= tag")
+		}
+		if !ed25519.Verify(pub, digest, sig) {
+			return fail("signature mismatch")
+		}
+	default:
+		return neutral("unsupported algorithm " + tags["a"])
+	}
+
+	result.Status = DKIMPass
+	return result
+}
+
+// lookupDKIMKey returns *rsa.PublicKey or ed25519.PublicKey, nil key without error means the key was revoked
+func lookupDKIMKey(lookup DKIMKeyLookup, name string) (crypto.PublicKey, error) {
+	records, err := lookup.LookupTXT(name)
+	if err != nil {
+		return nil, &dkimKeyError{"key lookup failed: " + err.Error()}
+	}
+	if len(records) == 0 {
+		return nil, &dkimKeyError{"no key for " + name}
+	}
+	tags := parseDKIMTags(records[0])
+	if v, ok := tags["v"]; ok && v != "DKIM1" {
+		return nil, &dkimKeyError{"unsupported key record version " + v}
+	}
+	p, ok := tags["p"]
+	if !ok {
+		return nil, &dkimKeyError{"key record has no p= tag"}
+	}
+	if p == "" {
+		return nil, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(p)
+	if err != nil {
+		return nil, &dkimKeyError{"malformed key"}
+	}
+
+	switch tags["k"] {
+	case "", "rsa":
+		if key, err := x509.ParsePKIXPublicKey(data); err == nil {
+			if rsaKey, ok := key.(*rsa.PublicKey); ok {
+				return rsaKey, nil
+			}
+		}
+		if key, err := x509.ParsePKCS1PublicKey(data); err == nil {
+			return key, nil
+		}
+		return nil, &dkimKeyError{"malformed RSA key"}
+	case "ed25519":
+		if len(data) != ed25519.PublicKeySize {
+			return nil, &dkimKeyError{"malformed Ed25519 key"}
+		}
+		return ed25519.PublicKey(data), nil
+	default:
+		return nil, &dkimKeyError{"unsupported key type " + tags["k"]}
+	}
+}
+
+type dkimKeyError struct {
+	reason string
+}
+
+func (e *dkimKeyError) Error() string {
+	return e.reason
+}
+
+// parseDKIMTags parses tag-list (RFC 6376 3.2), whitespace is removed from values
+func parseDKIMTags(list string) map[string]string {
+	tags := map[string]string{}
+	for _, tag := range strings.Split(list, ";") {
+		nameValue := strings.SplitN(tag, "=", 2)
+		if len(nameValue) != 2 {
+			continue
+		}
+		name := strings.TrimSpace(nameValue[0])
+		tags[name] = strings.Map(func(r rune) rune {
+			if r == ' ' || r == '\t' || r == '\r' || r == '\n' {
+				return -1
+			}
+			return r
+		}, nameValue[1])
+	}
+	return tags
+}
+
+// stripDKIMSignatureValue removes value of b= tag from DKIM-Signature field, keeping everything else as is
+func stripDKIMSignatureValue(raw string) string {
+	colon := strings.IndexByte(raw, ':')
+	start := colon + 1
+	for start <= len(raw) {
+		end := strings.IndexByte(raw[start:], ';')
+		if end < 0 {
+			end = len(raw)
+		} else {
+			end += start
+		}
+		tag := raw[start:end]
+		if eq := strings.IndexByte(tag, '='); eq >= 0 && strings.TrimSpace(tag[:eq]) == "b" {
+			value := raw[start+eq+1 : end]
+			// field CRLF stays
+			suffix := ""
+			if end == len(raw) && strings.HasSuffix(value, "\r\n") {
+				suffix = "\r\n"
+			}
+			return raw[:start+eq+1] + suffix + raw[end:]
+		}
+		start = end + 1
+	}
+	return raw
+}
+
+// splitMessage splits message with CRLF line endings into header block, with CRLF of the last field, and body
+func splitMessage(message []byte) ([]byte, []byte) {
+	if i := bytes.Index(message, []byte("\r\n\r\n")); i >= 0 {
+		return message[:i+2], message[i+4:]
+	}
+	return message, nil
+}
+
+// normalizeCRLF converts bare LF line endings to CRLF
+func normalizeCRLF(message []byte) []byte {
+	return toCRLF(bytes.Replace(message, []byte("\r\n"), []byte("\n"), -1))
+}
@@ -0,0 +1,167 @@
+package gmime
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// memoryKeyLookup is DKIMKeyLookup of TXT records by DNS name
+type memoryKeyLookup map[string]string
+
+func (l memoryKeyLookup) LookupTXT(name string) ([]string, error) {
+	record, ok := l[name]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return []string{record}, nil
+}
+
+const testDKIMMessage = "From: Sender <sender@example.com>\r\n" +
+	"To: rcpt@example.org\r\n" +
+	"Subject: Hello\r\n" +
+	"\r\n" +
+	"Body line\r\n"
+
+// signTestMessage returns message with DKIM-Signature header of s
+func signTestMessage(t *testing.T, s *DKIMSigner, message string) string {
+	fields, err := dkimSignatures([]*DKIMSigner{s}, []byte(message))
+	if err != nil {
+		t.Fatalf("dkimSignatures: %v", err)
+	}
+	return fields[0] + message
+}
+
+func TestVerifyDKIM(t *testing.T) {
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPrivate, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPublic, err := x509.MarshalPKIXPublicKey(&rsaPrivate.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lookup := memoryKeyLookup{
+		"ed._domainkey.example.com":      "v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(edPublic),
+		"rsa._domainkey.example.com":     "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(rsaPublic),
+		"revoked._domainkey.example.com": "v=DKIM1; k=ed25519; p=",
+	}
+	edSigner := &DKIMSigner{Domain: "example.com", Selector: "ed", Signer: edPrivate}
+
+	tests := []struct {
+		name   string
+		signer *DKIMSigner
+		edit   func(signed string) string
+		status DKIMStatus
+		reason string
+	}{
+		{
+			name:   "pass ed25519",
+			signer: edSigner,
+			status: DKIMPass,
+		},
+		{
+			name:   "pass rsa simple",
+			signer: &DKIMSigner{Domain: "example.com", Selector: "rsa", Signer: rsaPrivate, HeaderCanonicalization: CanonicalizationSimple, BodyCanonicalization: CanonicalizationSimple},
+			status: DKIMPass,
+		},
+		{
+			name:   "pass identity in subdomain",
+			signer: &DKIMSigner{Domain: "example.com", Selector: "ed", Identity: "sender@mail.example.com", Signer: edPrivate},
+			status: DKIMPass,
+		},
+		{
+			name:   "body edited",
+			signer: edSigner,
+			edit: func(signed string) string {
+				return strings.Replace(signed, "Body line", "Body line!", 1)
+			},
+			status: DKIMFail,
+			reason: "body hash mismatch",
+		},
+		{
+			name:   "header edited",
+			signer: edSigner,
+			edit: func(signed string) string {
+				return strings.Replace(signed, "Subject: Hello", "Subject: Goodbye", 1)
+			},
+			status: DKIMFail,
+			reason: "signature mismatch",
+		},
+		{
+			name:   "expired",
+			signer: edSigner,
+			edit: func(signed string) string {
+				// expiration is checked before the signature, so x= needs not be signed
+				return strings.Replace(signed, "DKIM-Signature: v=1;", "DKIM-Signature: v=1; x=1;", 1)
+			},
+			status: DKIMFail,
+			reason: "signature expired",
+		},
+		{
+			name:   "revoked key",
+			signer: &DKIMSigner{Domain: "example.com", Selector: "revoked", Signer: edPrivate},
+			status: DKIMFail,
+			reason: "key revoked",
+		},
+		{
+			name:   "identity outside domain",
+			signer: &DKIMSigner{Domain: "example.com", Selector: "ed", Identity: "sender@example.net", Signer: edPrivate},
+			status: DKIMNeutral,
+			reason: "i= domain is not d= domain or its subdomain",
+		},
+		{
+			name:   "identity in domain suffix",
+			signer: &DKIMSigner{Domain: "example.com", Selector: "ed", Identity: "@notexample.com", Signer: edPrivate},
+			status: DKIMNeutral,
+			reason: "i= domain is not d= domain or its subdomain",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signed := signTestMessage(t, tt.signer, testDKIMMessage)
+			if tt.edit != nil {
+				signed = tt.edit(signed)
+			}
+			results := VerifyDKIM(&ParsedMessage{Raw: []byte(signed)}, lookup)
+			if len(results) != 1 {
+				t.Fatalf("got %d results, want 1", len(results))
+			}
+			r := results[0]
+			if r.Status != tt.status || r.Reason != tt.reason {
+				t.Errorf("got %s %q, want %s %q", r.Status, r.Reason, tt.status, tt.reason)
+			}
+			if r.Domain != tt.signer.Domain || r.Selector != tt.signer.Selector {
+				t.Errorf("got d=%s s=%s, want d=%s s=%s", r.Domain, r.Selector, tt.signer.Domain, tt.signer.Selector)
+			}
+		})
+	}
+}
+
+func TestVerifyDKIMLFLineEndings(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lookup := memoryKeyLookup{
+		"ed._domainkey.example.com": "k=ed25519; p=" + base64.StdEncoding.EncodeToString(private.Public().(ed25519.PublicKey)),
+	}
+	signed := signTestMessage(t, &DKIMSigner{Domain: "example.com", Selector: "ed", Signer: private}, testDKIMMessage)
+
+	// Export writes LF line endings, verification converts them back
+	raw := bytes.Replace([]byte(signed), []byte("\r\n"), []byte("\n"), -1)
+	results := VerifyDKIM(&ParsedMessage{Raw: raw}, lookup)
+	if len(results) != 1 || results[0].Status != DKIMPass {
+		t.Fatalf("got %+v, want one pass", results)
+	}
+}
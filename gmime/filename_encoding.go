@@ -0,0 +1,184 @@
+package gmime
+
+/*
+#cgo pkg-config: gmime-2.6
+#include <stdlib.h>
+#include <gmime/gmime.h>
+
+ssize_t raw_header_writer(GMimeStream *stream, const char *name, const char *value);
+*/
+import "C"
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+	"unsafe"
+)
+
+var (
+	cStringContentType        = C.CString("Content-Type")
+	cStringContentDisposition = C.CString("Content-Disposition")
+)
+
+// FileNameEncoding is how non-ASCII attachment file names are written into
+// Content-Type name and Content-Disposition filename parameters, ASCII names are always quoted strings
+type FileNameEncoding int
+
+const (
+	// FileNameEncodingDefault lets GMime encode parameters
+	FileNameEncodingDefault FileNameEncoding = iota
+	// FileNameEncodingRFC2231 writes filename*=utf-8''%D0%B0... with continuations for long names
+	FileNameEncodingRFC2231
+	// FileNameEncodingRFC2047 writes filename="=?utf-8?b?...?=", which Outlook and Gmail expect
+	FileNameEncodingRFC2047
+	// FileNameEncodingBoth writes RFC 2047 parameter followed by RFC 2231 one,
+	// clients which support RFC 2231 prefer it
+	FileNameEncodingBoth
+)
+
+// RFC 2231 continuation length, it keeps folded lines within 78 characters
+const rfc2231Chunk = 60
+
+// SetFileNameEncoding sets how exports write non-ASCII file names of attachments and embeds
+func (m *Message) SetFileNameEncoding(e FileNameEncoding) {
+	m.fileNameEncoding = e
+}
+
+// setFileName sets name and filename parameters of part, it should go after other parameters are set
+func setFileName(part *C.GMimePart, fileName string, encoding FileNameEncoding) {
+	cFileName := C.CString(fileName) // needs free
+	C.g_mime_part_set_filename(part, cFileName)
+	C.free(unsafe.Pointer(cFileName)) // free
+	if encoding == FileNameEncodingDefault || isASCII(fileName) {
+		return
+	}
+
+	// GMime would encode parameters on its own, so the headers are formatted here and written as is
+	obj := anyToGMimeObject(unsafe.Pointer(part))
+	headerList := C.g_mime_object_get_header_list(obj)
+
+	contentType := C.g_mime_object_get_content_type(obj)
+	mediaType := C.g_mime_content_type_to_string(contentType) // needs g_free
+	contentTypeValue := formatParams("Content-Type", C.GoString(mediaType),
+		otherParams(C.g_mime_content_type_get_params(contentType), "name"),
+		fileNameParams("name", fileName, encoding))
+	C.g_free(C.gpointer(mediaType))
+
+	setRawHeader(headerList, cStringContentType, contentTypeValue)
+
+	// set_filename creates disposition if there is none
+	if disposition := C.g_mime_object_get_content_disposition(obj); disposition != nil {
+		dispositionValue := formatParams("Content-Disposition", C.GoString(C.g_mime_content_disposition_get_disposition(disposition)),
+			otherParams(C.g_mime_content_disposition_get_params(disposition), "filename"),
+			fileNameParams("filename", fileName, encoding))
+		setRawHeader(headerList, cStringContentDisposition, dispositionValue)
+	}
+}
+
+// setRawHeader replaces value of header name with value written as is
+func setRawHeader(headerList *C.GMimeHeaderList, name *C.char, value string) {
+	cValue := C.CString(value) // needs free
+	C.g_mime_header_list_register_writer(headerList, name, (C.GMimeHeaderWriter)(unsafe.Pointer(C.raw_header_writer)))
+	C.g_mime_header_list_set(headerList, name, cValue)
+	C.free(unsafe.Pointer(cValue)) // free
+}
+
+// otherParams returns parameters of list but skip, as name=value
+func otherParams(param *C.GMimeParam, skip string) []string {
+	var params []string
+	for ; param != nil; param = C.g_mime_param_next(param) {
+		name := C.GoString(C.g_mime_param_get_name(param))
+		if strings.EqualFold(name, skip) {
+			continue
+		}
+		params = append(params, name+"="+quoteParamValue(C.GoString(C.g_mime_param_get_value(param))))
+	}
+	return params
+}
+
+// fileNameParams returns encoded name parameter of fileName, as name=value
+func fileNameParams(name, fileName string, encoding FileNameEncoding) []string {
+	var params []string
+	if encoding == FileNameEncodingRFC2047 || encoding == FileNameEncodingBoth {
+		value := C.CString(fileName)                        // needs free
+		encoded := C.g_mime_utils_header_encode_text(value) // needs g_free
+		params = append(params, name+"="+quoteParamValue(C.GoString(encoded)))
+		C.g_free(C.gpointer(encoded))
+		C.free(unsafe.Pointer(value)) // free
+	}
+	if encoding == FileNameEncodingRFC2231 || encoding == FileNameEncodingBoth {
+		params = append(params, rfc2231Params(name, fileName)...)
+	}
+	return params
+}
+
+// rfc2231Params returns percent-encoded UTF-8 name*= parameter, or name*0*=, name*1*= continuations
+// for a long value, RFC 2231 sections 3 and 4. Continuations are split between characters,
+// so every one of them decodes to valid UTF-8
+func rfc2231Params(name, value string) []string {
+	// encoded characters, a multi-byte one is a single item
+	var chars []string
+	length := len("utf-8''")
+	for i := 0; i < len(value); {
+		_, size := utf8.DecodeRuneInString(value[i:])
+		var char strings.Builder
+		for _, c := range []byte(value[i : i+size]) {
+			if isAttributeChar(c) {
+				char.WriteByte(c)
+			} else {
+				fmt.Fprintf(&char, "%%%02X", c)
+			}
+		}
+		chars = append(chars, char.String())
+		length += char.Len()
+		i += size
+	}
+	if length <= rfc2231Chunk {
+		return []string{name + "*=utf-8''" + strings.Join(chars, "")}
+	}
+	var params []string
+	chunk := "utf-8''"
+	for _, char := range chars {
+		if len(chunk)+len(char) > rfc2231Chunk && chunk != "" {
+			params = append(params, fmt.Sprintf("%s*%d*=%s", name, len(params), chunk))
+			chunk = ""
+		}
+		chunk += char
+	}
+	return append(params, fmt.Sprintf("%s*%d*=%s", name, len(params), chunk))
+}
+
+// isAttributeChar checks attribute-char of RFC 2231, which is written unencoded
+func isAttributeChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", c) >= 0
+}
+
+func quoteParamValue(value string) string {
+	value = strings.Replace(value, `\`, `\\`, -1)
+	return `"` + strings.Replace(value, `"`, `\"`, -1) + `"`
+}
+
+// formatParams returns header value of value and params, folded so lines fit 78 characters
+func formatParams(name, value string, paramLists ...[]string) string {
+	var formatted strings.Builder
+	formatted.WriteString(value)
+	line := len(name) + len(": ") + len(value)
+	for _, params := range paramLists {
+		for _, param := range params {
+			if line+len("; ")+len(param) > foldHeaderLine {
+				formatted.WriteString(";\n\t")
+				line = 1
+			} else {
+				formatted.WriteString("; ")
+				line += 2
+			}
+			formatted.WriteString(param)
+			line += len(param)
+		}
+	}
+	return formatted.String()
+}
@@ -0,0 +1,125 @@
+package gmime
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestRFC2231Params(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  []string
+	}{
+		{
+			name:  "one param",
+			value: `отчёт "1";%.pdf`,
+			want:  []string{"filename*=utf-8''%D0%BE%D1%82%D1%87%D1%91%D1%82%20%221%22%3B%25.pdf"},
+		},
+		{
+			name:  "character at chunk end",
+			value: strings.Repeat("a", 50) + "é",
+			want: []string{
+				"filename*0*=utf-8''" + strings.Repeat("a", 50),
+				"filename*1*=%C3%A9",
+			},
+		},
+		{
+			name:  "character fills chunk",
+			value: strings.Repeat("a", 44) + "報" + "b",
+			want: []string{
+				"filename*0*=utf-8''" + strings.Repeat("a", 44) + "%E5%A0%B1",
+				"filename*1*=b",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rfc2231Params("filename", tt.value)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestRFC2231ParamsContinuations(t *testing.T) {
+	for _, value := range []string{
+		strings.Repeat("Квартальный отчёт ", 8) + ".pdf",
+		strings.Repeat("四半期報告書", 10) + ".xlsx",
+		strings.Repeat("a📎🎉", 20) + ".zip",
+	} {
+		var joined string
+		for i, param := range rfc2231Params("filename", value) {
+			chunk := strings.TrimPrefix(param, fmt.Sprintf("filename*%d*=", i))
+			if chunk == param || len(chunk) > rfc2231Chunk {
+				t.Errorf("%q: param %d is %q", value, i, param)
+			}
+			// every continuation decodes to whole characters
+			if decoded, err := url.PathUnescape(strings.TrimPrefix(chunk, "utf-8''")); err != nil || !utf8.ValidString(decoded) {
+				t.Errorf("%q: chunk %q splits a character", value, chunk)
+			}
+			joined += chunk
+		}
+		if decoded, err := url.PathUnescape(strings.TrimPrefix(joined, "utf-8''")); err != nil || decoded != value {
+			t.Errorf("%q: joined chunks decode to %q", value, decoded)
+		}
+	}
+}
+
+func TestFormatParams(t *testing.T) {
+	got := formatParams("Content-Disposition", "attachment", []string{`size="1024"`}, rfc2231Params("filename", strings.Repeat("a", 52)+"é"))
+	want := "attachment; size=\"1024\";\n" +
+		"\tfilename*0*=utf-8''" + strings.Repeat("a", 52) + ";\n" +
+		"\tfilename*1*=%C3%A9"
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if got := formatParams("Content-Type", "text/plain", []string{`charset="utf-8"`}, []string{`name="a.txt"`}); got != `text/plain; charset="utf-8"; name="a.txt"` {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestExportFileNameEncoding(t *testing.T) {
+	tests := []struct {
+		encoding FileNameEncoding
+		contains []string // in Content-Disposition of non-ASCII names
+	}{
+		{FileNameEncodingDefault, nil},
+		{FileNameEncodingRFC2231, []string{"filename*"}},
+		{FileNameEncodingRFC2047, []string{`filename="=?`}},
+		{FileNameEncodingBoth, []string{`filename="=?`, "filename*"}},
+	}
+	for _, tt := range tests {
+		for _, name := range []string{"Квартальный отчёт по продажам за третий квартал 2024 года.pdf", "🎉 party.png", "report.pdf"} {
+			m := NewMessage()
+			m.AddAddress(&EmailAddress{AddressType: AddressFrom, Address: "sender@example.com"})
+			m.SetText([]byte("see attachment"))
+			m.SetFileNameEncoding(tt.encoding)
+			m.Attach(&EmailAttachment{FileName: name, MimeType: "application/octet-stream", Content: []byte("data")})
+			data, parsed := exportParsed(t, m)
+
+			if !isASCII(name) {
+				for _, s := range tt.contains {
+					if !bytes.Contains(data, []byte(s)) {
+						t.Errorf("encoding %d %q: export has no %s:\n%s", tt.encoding, name, s, data)
+					}
+				}
+				if tt.encoding == FileNameEncodingRFC2231 {
+					for _, line := range strings.Split(string(data), "\n") {
+						if len(line) > foldHeaderLine {
+							t.Errorf("encoding %d %q: line is %d characters: %q", tt.encoding, name, len(line), line)
+						}
+					}
+				}
+			}
+			if len(parsed.Attachments) != 1 || parsed.Attachments[0].FileName != name {
+				t.Errorf("encoding %d: attachments %+v, want file name %q", tt.encoding, parsed.Attachments, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+#include "go_stream.h"
+#include "_cgo_export.h"
+
+G_DEFINE_TYPE(GoStream, go_stream, GMIME_TYPE_STREAM)
+
+static void go_stream_finalize(GObject *object) {
+	goStreamRelease(((GoStream *)object)->handle);
+	G_OBJECT_CLASS(go_stream_parent_class)->finalize(object);
+}
+
+static ssize_t go_stream_read(GMimeStream *stream, char *buf, size_t len) {
+	GoStream *gostream = (GoStream *)stream;
+	ssize_t nread;
+
+	if (stream->bound_end != -1) {
+		// known size, don't read past it
+		if (stream->position >= stream->bound_end) {
+			gostream->eos = TRUE;
+		}
+		len = MIN(len, (size_t)(stream->bound_end - stream->position));
+	}
+	if (gostream->eos) {
+		return 0;
+	}
+	nread = goStreamRead(gostream->handle, buf, len);
+	if (nread <= 0) {
+		// io.EOF or error, either way there is nothing more to read
+		gostream->eos = TRUE;
+	}
+	if (nread > 0) {
+		stream->position += nread;
+	}
+	return nread;
+}
+
+static ssize_t go_stream_write(GMimeStream *stream, const char *buf, size_t len) {
+	ssize_t nwritten = goStreamWrite(((GoStream *)stream)->handle, (char *)buf, len);
+	if (nwritten > 0) {
+		stream->position += nwritten;
+	}
+	return nwritten;
+}
+
+static int go_stream_flush(GMimeStream *stream) {
+	return 0;
+}
+
+static int go_stream_close(GMimeStream *stream) {
+	return 0;
+}
+
+static gboolean go_stream_eos(GMimeStream *stream) {
+	if (stream->bound_end != -1 && stream->position >= stream->bound_end) {
+		return TRUE;
+	}
+	return ((GoStream *)stream)->eos;
+}
+
+static int go_stream_reset(GMimeStream *stream) {
+	// Go readers and writers can't rewind, reset is a no-op until something was read or written
+	return stream->position == stream->bound_start ? 0 : -1;
+}
+
+static gint64 go_stream_seek(GMimeStream *stream, gint64 offset, GMimeSeekWhence whence) {
+	return -1;
+}
+
+static gint64 go_stream_tell(GMimeStream *stream) {
+	return stream->position;
+}
+
+static gint64 go_stream_length(GMimeStream *stream) {
+	if (stream->bound_end == -1) {
+		return -1;
+	}
+	return stream->bound_end - stream->bound_start;
+}
+
+static GMimeStream *go_stream_substream(GMimeStream *stream, gint64 start, gint64 end) {
+	return NULL;
+}
+
+static void go_stream_class_init(GoStreamClass *klass) {
+	GObjectClass *object_class = G_OBJECT_CLASS(klass);
+	GMimeStreamClass *stream_class = GMIME_STREAM_CLASS(klass);
+
+	object_class->finalize = go_stream_finalize;
+
+	stream_class->read = go_stream_read;
+	stream_class->write = go_stream_write;
+	stream_class->flush = go_stream_flush;
+	stream_class->close = go_stream_close;
+	stream_class->eos = go_stream_eos;
+	stream_class->reset = go_stream_reset;
+	stream_class->seek = go_stream_seek;
+	stream_class->tell = go_stream_tell;
+	stream_class->length = go_stream_length;
+	stream_class->substream = go_stream_substream;
+}
+
+static void go_stream_init(GoStream *gostream) {
+	gostream->handle = 0;
+	gostream->eos = FALSE;
+}
+
+GMimeStream *go_stream_new(uintptr_t handle) {
+	GoStream *gostream = g_object_new(go_stream_get_type(), NULL);
+	gostream->handle = handle;
+	g_mime_stream_construct((GMimeStream *)gostream, 0, -1);
+	return (GMimeStream *)gostream;
+}
@@ -0,0 +1,85 @@
+package gmime
+
+/*
+#cgo pkg-config: gmime-2.6
+#include <stdlib.h>
+#include "go_stream.h"
+*/
+import "C"
+import (
+	"io"
+	"reflect"
+	"runtime/cgo"
+	"unsafe"
+)
+
+// reads of r which return no data and no error before goStreamRead gives up, as in bufio
+const maxConsecutiveEmptyReads = 100
+
+// goStream is the Go side of GoStream, see go_stream.c
+type goStream struct {
+	r   io.Reader
+	w   io.Writer
+	n   int64 // bytes read from r or written to w
+	err error // first error returned by r or w, io.EOF is not an error
+}
+
+// returns GMimeStream backed by s
+// caller responsible for unref
+func newGoStream(s *goStream) *C.GMimeStream {
+	return C.go_stream_new(C.uintptr_t(cgo.NewHandle(s)))
+}
+
+func goBytes(buf *C.char, length C.size_t) []byte {
+	h := reflect.SliceHeader{
+		Data: uintptr(unsafe.Pointer(buf)),
+		Len:  int(length),
+		Cap:  int(length),
+	}
+	return *(*[]byte)(unsafe.Pointer(&h))
+}
+
+//export goStreamRead
+func goStreamRead(handle C.uintptr_t, buf *C.char, length C.size_t) C.ssize_t {
+	s := cgo.Handle(handle).Value().(*goStream)
+	if s.r == nil || s.err != nil {
+		return -1
+	}
+	p := goBytes(buf, length)
+	for i := 0; ; i++ {
+		if i == maxConsecutiveEmptyReads {
+			s.err = io.ErrNoProgress
+			return -1
+		}
+		n, err := s.r.Read(p)
+		s.n += int64(n)
+		if err != nil && err != io.EOF {
+			s.err = err
+			return -1
+		}
+		if n > 0 || err == io.EOF {
+			// 0 tells GoStream that the reader is exhausted
+			return C.ssize_t(n)
+		}
+	}
+}
+
+//export goStreamWrite
+func goStreamWrite(handle C.uintptr_t, buf *C.char, length C.size_t) C.ssize_t {
+	s := cgo.Handle(handle).Value().(*goStream)
+	if s.w == nil || s.err != nil {
+		return -1
+	}
+	n, err := s.w.Write(goBytes(buf, length))
+	s.n += int64(n)
+	if err != nil {
+		s.err = err
+		return -1
+	}
+	return C.ssize_t(n)
+}
+
+//export goStreamRelease
+func goStreamRelease(handle C.uintptr_t) {
+	cgo.Handle(handle).Delete()
+}
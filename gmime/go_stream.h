@@ -0,0 +1,24 @@
+#ifndef GO_GMIME_GO_STREAM_H
+#define GO_GMIME_GO_STREAM_H
+
+#include <stdint.h>
+#include <gmime/gmime.h>
+
+// GoStream is a GMimeStream which reads from and writes to Go io.Reader/io.Writer
+// identified by a runtime/cgo handle
+typedef struct _GoStream {
+	GMimeStream parent_object;
+	uintptr_t handle;
+	gboolean eos;
+} GoStream;
+
+typedef struct _GoStreamClass {
+	GMimeStreamClass parent_class;
+} GoStreamClass;
+
+GType go_stream_get_type(void);
+
+// go_stream_new returns unbound stream, handle is released when stream is finalized
+GMimeStream *go_stream_new(uintptr_t handle);
+
+#endif
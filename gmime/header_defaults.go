@@ -0,0 +1,112 @@
+package gmime
+
+/*
+#cgo pkg-config: gmime-2.6
+#include <stdlib.h>
+#include <gmime/gmime.h>
+*/
+import "C"
+import (
+	"strings"
+	"time"
+	"unsafe"
+)
+
+var (
+	cStringMIMEVersion      = C.CString("MIME-Version")
+	cStringMIMEVersionValue = C.CString("1.0")
+)
+
+// HeaderDefaults configures headers every exported message has. Date, Message-Id and
+// MIME-Version are always present: a header added by AppendHeader or PrependHeader wins,
+// otherwise the default is generated. Defaults take the places GMime reserves for
+// standard message headers, headers of AppendHeader and PrependHeader go after them
+type HeaderDefaults struct {
+	// MessageIDDomain is domain part of generated Message-Id,
+	// domain of From address if empty, host name if there is no From
+	MessageIDDomain string
+	// MessageIDGenerator returns Message-Id without angle brackets for domain,
+	// GMime generator (time, pid, random and domain) if nil. Export fails with *HeaderError
+	// if the result has CR or LF
+	MessageIDGenerator func(domain string) string
+	// Clock returns Date of exported messages, time.Now if nil
+	Clock func() time.Time
+}
+
+// SetHeaderDefaults replaces default configuration of Date and Message-Id, nil restores it
+func (m *Message) SetHeaderDefaults(d *HeaderDefaults) {
+	m.headerDefaults = d
+}
+
+// injectHeaderDefaults adds headers of d which are not in headers, d may be nil.
+// It returns *HeaderError if MessageIDGenerator returns CR or LF
+func injectHeaderDefaults(message *C.GMimeMessage, d *HeaderDefaults, headers []*EmailHeader, addresses []*EmailAddress) error {
+	if d == nil {
+		d = &HeaderDefaults{}
+	}
+	obj := anyToGMimeObject(unsafe.Pointer(message))
+
+	if !hasHeader(headers, "Date") {
+		now := time.Now()
+		if d.Clock != nil {
+			now = d.Clock()
+		}
+		C.g_mime_message_set_date(message, C.time_t(now.Unix()), C.int(tzOffset(now)))
+	}
+
+	if !hasHeader(headers, "Message-Id") {
+		domain := d.MessageIDDomain
+		if domain == "" {
+			for _, a := range addresses {
+				if a.AddressType == AddressFrom {
+					if at := strings.LastIndexByte(a.Address, '@'); at >= 0 {
+						domain = a.Address[at+1:]
+					}
+					break
+				}
+			}
+		}
+		var messageID *C.char
+		if d.MessageIDGenerator != nil {
+			generated := d.MessageIDGenerator(domain)
+			if strings.ContainsAny(generated, "\r\n") {
+				return &HeaderError{Name: "Message-Id", Err: ErrHeaderNewline}
+			}
+			messageID = C.CString(generated)        // needs free
+			defer C.free(unsafe.Pointer(messageID)) // free
+		} else {
+			var fqdn *C.char // NULL makes GMime use host name
+			if domain != "" {
+				fqdn = C.CString(domain)           // needs free
+				defer C.free(unsafe.Pointer(fqdn)) // free
+			}
+			messageID = C.g_mime_utils_generate_message_id(fqdn) // needs g_free
+			defer C.g_free(C.gpointer(messageID))                // g_free
+		}
+		C.g_mime_message_set_message_id(message, messageID)
+	}
+
+	if !hasHeader(headers, "MIME-Version") {
+		C.g_mime_object_set_header(obj, cStringMIMEVersion, cStringMIMEVersionValue)
+	}
+	return nil
+}
+
+func hasHeader(headers []*EmailHeader, name string) bool {
+	for _, h := range headers {
+		if strings.EqualFold(h.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// tzOffset returns zone offset of t in the form GMime expects, e.g. -0130 is -130
+func tzOffset(t time.Time) int {
+	_, offset := t.Zone()
+	sign := 1
+	if offset < 0 {
+		sign, offset = -1, -offset
+	}
+	return sign * (offset/3600*100 + offset%3600/60)
+}
@@ -0,0 +1,98 @@
+package gmime
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestDefaultsMessage() *Message {
+	m := NewMessage()
+	m.AddAddress(&EmailAddress{AddressType: AddressFrom, Address: "sender@mail.example.com"})
+	m.AddAddress(&EmailAddress{AddressType: AddressTo, Address: "rcpt@example.org"})
+	m.SetText([]byte("defaults"))
+	return m
+}
+
+func TestTZOffset(t *testing.T) {
+	tests := []struct {
+		offset int // seconds east of UTC
+		want   int
+	}{
+		{0, 0},
+		{2 * 3600, 200},
+		{5*3600 + 30*60, 530},
+		{-(3*3600 + 30*60), -330},
+		{-(30 * 60), -30},
+		{-(12 * 3600), -1200},
+	}
+	for _, tt := range tests {
+		now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.FixedZone("", tt.offset))
+		if got := tzOffset(now); got != tt.want {
+			t.Errorf("offset %d: got %d, want %d", tt.offset, got, tt.want)
+		}
+	}
+}
+
+func TestHeaderDefaultsDate(t *testing.T) {
+	m := newTestDefaultsMessage()
+	m.SetHeaderDefaults(&HeaderDefaults{Clock: func() time.Time {
+		return time.Date(2024, 3, 1, 10, 4, 5, 0, time.FixedZone("", -(3*3600+30*60)))
+	}})
+	_, parsed := exportParsed(t, m)
+	if got := headerValues(parsed, "Date"); len(got) != 1 || got[0] != "Fri, 01 Mar 2024 10:04:05 -0330" {
+		t.Errorf("Date %q", got)
+	}
+	if got := headerValues(parsed, "MIME-Version"); len(got) != 1 || got[0] != "1.0" {
+		t.Errorf("MIME-Version %q", got)
+	}
+}
+
+func TestHeaderDefaultsMessageID(t *testing.T) {
+	tests := []struct {
+		name     string
+		defaults *HeaderDefaults
+		suffix   string
+	}{
+		{"domain of From", nil, "@mail.example.com>"},
+		{"MessageIDDomain", &HeaderDefaults{MessageIDDomain: "ids.example.net"}, "@ids.example.net>"},
+		{"MessageIDGenerator", &HeaderDefaults{MessageIDGenerator: func(domain string) string { return "fixed." + domain }}, "<fixed.mail.example.com>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestDefaultsMessage()
+			m.SetHeaderDefaults(tt.defaults)
+			_, parsed := exportParsed(t, m)
+			if got := headerValues(parsed, "Message-Id"); len(got) != 1 || !strings.HasPrefix(got[0], "<") || !strings.HasSuffix(got[0], tt.suffix) {
+				t.Errorf("Message-Id %q, want suffix %q", got, tt.suffix)
+			}
+		})
+	}
+}
+
+func TestHeaderDefaultsGeneratorNewline(t *testing.T) {
+	m := newTestDefaultsMessage()
+	m.SetHeaderDefaults(&HeaderDefaults{MessageIDGenerator: func(domain string) string { return "a\r\nBcc: x@" + domain }})
+	_, err := m.Export()
+	if he, ok := err.(*HeaderError); !ok || he.Name != "Message-Id" || he.Err != ErrHeaderNewline {
+		t.Errorf("got %v, want Message-Id HeaderError", err)
+	}
+}
+
+func TestHeaderDefaultsUserHeaders(t *testing.T) {
+	m := newTestDefaultsMessage()
+	m.SetHeaderDefaults(&HeaderDefaults{Clock: func() time.Time { return time.Unix(0, 0) }})
+	m.AppendHeader(&EmailHeader{Name: "Date", Value: "Sat, 02 Mar 2024 08:00:00 +0000"})
+	m.AppendHeader(&EmailHeader{Name: "Message-ID", Value: "<user@example.com>"})
+	m.AppendHeader(&EmailHeader{Name: "Mime-Version", Value: "1.0"})
+	_, parsed := exportParsed(t, m)
+	if got := headerValues(parsed, "Date"); len(got) != 1 || got[0] != "Sat, 02 Mar 2024 08:00:00 +0000" {
+		t.Errorf("Date %q", got)
+	}
+	if got := headerValues(parsed, "Message-Id"); len(got) != 1 || got[0] != "<user@example.com>" {
+		t.Errorf("Message-Id %q", got)
+	}
+	if got := headerValues(parsed, "MIME-Version"); len(got) != 1 {
+		t.Errorf("MIME-Version %q", got)
+	}
+}
@@ -0,0 +1,63 @@
+package gmime
+
+import "strings"
+
+// FoldingPolicy is how a message or part header value is split into lines.
+// GMime has one writer per header name, so once a header of a name has a policy other than
+// FoldingDefault, FoldingDefault headers of that name are folded like FoldingWhitespace
+type FoldingPolicy int
+
+const (
+	// FoldingDefault lets GMime encode and fold the value
+	FoldingDefault FoldingPolicy = iota
+	// FoldingRaw writes the value as is, it may be folded already. Same as Raw
+	FoldingRaw
+	// FoldingWhitespace folds at whitespace so lines fit 78 characters where words allow
+	FoldingWhitespace
+	// FoldingHardLimit folds at whitespace only where a line would exceed 998 characters
+	FoldingHardLimit
+)
+
+// recommended line length, RFC 5322 section 2.1.1
+const foldHeaderLine = 78
+
+func (h *EmailHeader) folding() FoldingPolicy {
+	if h.Raw {
+		return FoldingRaw
+	}
+	return h.Folding
+}
+
+// rawWriterNames returns lower case names of headers which are folded in Go and written by raw writer
+func rawWriterNames(headers []*EmailHeader) map[string]bool {
+	names := map[string]bool{}
+	for _, h := range headers {
+		if h.folding() != FoldingDefault {
+			names[strings.ToLower(h.Name)] = true
+		}
+	}
+	return names
+}
+
+// foldHeader returns value folded so "name: value" lines fit width where words allow,
+// whitespace runs become single spaces. Words longer than a line are checked by validateHeader
+func foldHeader(name, value string, width int) string {
+	words := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ' ' || r == '\t'
+	})
+	var folded strings.Builder
+	line := len(name) + len(": ")
+	for i, word := range words {
+		if i > 0 {
+			if line+1+len(word) > width {
+				folded.WriteString("\n")
+				line = 0
+			}
+			folded.WriteString(" ")
+			line++
+		}
+		folded.WriteString(word)
+		line += len(word)
+	}
+	return folded.String()
+}
@@ -0,0 +1,106 @@
+package gmime
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// longHeaderValue has 30 words, it needs several 78 character lines
+var longHeaderValue = strings.TrimSpace(strings.Repeat("folding ", 30))
+
+// headerLines returns header lines of data, part headers too
+func headerLines(data []byte) []string {
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.Contains(line, ":") || strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func TestHeaderFoldingPolicies(t *testing.T) {
+	tests := []struct {
+		name   string
+		header *EmailHeader
+		want   string // header as written, "" to only check line lengths
+	}{
+		{
+			name:   "default",
+			header: &EmailHeader{Name: "X-Folded", Value: longHeaderValue},
+		},
+		{
+			name:   "raw",
+			header: &EmailHeader{Name: "X-Folded", Value: "already\n folded", Folding: FoldingRaw},
+			want:   "X-Folded: already\n folded\n",
+		},
+		{
+			name:   "whitespace",
+			header: &EmailHeader{Name: "X-Folded", Value: longHeaderValue, Folding: FoldingWhitespace},
+			want:   "X-Folded: " + foldHeader("X-Folded", longHeaderValue, foldHeaderLine) + "\n",
+		},
+		{
+			name:   "hard limit",
+			header: &EmailHeader{Name: "X-Folded", Value: longHeaderValue, Folding: FoldingHardLimit},
+			want:   "X-Folded: " + longHeaderValue + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMessage()
+			m.AddAddress(&EmailAddress{AddressType: AddressFrom, Address: "sender@example.com"})
+			m.AppendHeader(tt.header)
+			m.Attach(&EmailAttachment{FileName: "a.txt", MimeType: "text/plain", Content: []byte("a"), Headers: []*EmailHeader{tt.header}})
+			m.SetText([]byte("text"))
+			data, parsed := exportParsed(t, m)
+			if got := parsed.Header("X-Folded"); got != strings.Replace(tt.header.Value, "\n", "", -1) {
+				t.Errorf("X-Folded %q", got)
+			}
+			if tt.want == "" {
+				for _, line := range headerLines(data) {
+					if len(line) > foldHeaderLine {
+						t.Errorf("line is %d characters: %q", len(line), line)
+					}
+				}
+				return
+			}
+			// once in message headers, once in attachment headers
+			if n := bytes.Count(data, []byte(tt.want)); n != 2 {
+				t.Errorf("export has %d of\n%s\n%s", n, tt.want, data)
+			}
+		})
+	}
+}
+
+func TestHeaderFoldingMixedPolicies(t *testing.T) {
+	m := NewMessage()
+	m.AddAddress(&EmailAddress{AddressType: AddressFrom, Address: "sender@example.com"})
+	for i := 0; i < 8; i++ {
+		m.AddAddress(&EmailAddress{AddressType: AddressTo, Name: "Recipient Name", Address: "recipient" + strings.Repeat("x", i) + "@example.org"})
+	}
+	m.AppendHeader(&EmailHeader{Name: "X-Mixed", Value: "raw", Folding: FoldingRaw})
+	m.AppendHeader(&EmailHeader{Name: "X-Mixed", Value: longHeaderValue})
+	// To header GMime writes from addresses shares the raw writer
+	m.AppendHeader(&EmailHeader{Name: "To", Value: "ignored@example.org", Folding: FoldingRaw})
+	m.SetText([]byte("text"))
+	data, parsed := exportParsed(t, m)
+
+	for _, line := range headerLines(data) {
+		if len(line) > foldHeaderLine {
+			t.Errorf("line is %d characters: %q", len(line), line)
+		}
+	}
+	if !bytes.Contains(data, []byte("X-Mixed: raw\nX-Mixed: "+foldHeader("X-Mixed", longHeaderValue, foldHeaderLine)+"\n")) {
+		t.Errorf("X-Mixed headers are not folded:\n%s", data)
+	}
+	var to int
+	for _, a := range parsed.Addresses {
+		if a.AddressType == AddressTo {
+			to++
+		}
+	}
+	if to != 8 || bytes.Contains(data, []byte("ignored@example.org")) {
+		t.Errorf("got %d To addresses, want 8 from AddAddress", to)
+	}
+}
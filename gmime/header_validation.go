@@ -0,0 +1,198 @@
+package gmime
+
+import (
+	"errors"
+	"strings"
+)
+
+// maxHeaderLine is the limit of line length without CRLF, RFC 5322 section 2.1.1
+const maxHeaderLine = 998
+
+var (
+	ErrHeaderName       = errors.New("Header name should be printable ASCII without colon")
+	ErrHeaderNewline    = errors.New("Header value has CR or LF which doesn't fold it")
+	ErrHeaderLineLength = errors.New("Header line is longer than 998 characters and can't be folded")
+	ErrHeaderGenerated  = errors.New("Header is generated from attachment fields and can't be in its Headers")
+)
+
+// HeaderError is returned by exports for a header which could inject other headers or break the message,
+// Err is one of ErrHeaderName, ErrHeaderNewline, ErrHeaderLineLength, ErrHeaderGenerated
+type HeaderError struct {
+	Name string
+	Err  error
+}
+
+func (e *HeaderError) Error() string {
+	return e.Err.Error() + ": " + e.Name
+}
+
+func (e *HeaderError) Unwrap() error {
+	return e.Err
+}
+
+// validateHeaders checks headers before they go into GMime
+func validateHeaders(headers []*EmailHeader) error {
+	for _, h := range headers {
+		if err := validateHeader(h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateHeader(h *EmailHeader) error {
+	if !validHeaderName(h.Name) {
+		return &HeaderError{Name: h.Name, Err: ErrHeaderName}
+	}
+	if h.folding() == FoldingRaw {
+		// raw value is written as is, it may be folded already
+		lines := strings.Split(strings.Replace(h.Value, "\r\n", "\n", -1), "\n")
+		for i, line := range lines {
+			if strings.IndexByte(line, '\r') >= 0 || (i > 0 && (line == "" || (line[0] != ' ' && line[0] != '\t'))) {
+				return &HeaderError{Name: h.Name, Err: ErrHeaderNewline}
+			}
+			length := len(line)
+			if i == 0 {
+				length += len(h.Name) + len(": ")
+			}
+			if length > maxHeaderLine {
+				return &HeaderError{Name: h.Name, Err: ErrHeaderLineLength}
+			}
+		}
+		return nil
+	}
+
+	if strings.ContainsAny(h.Value, "\r\n") {
+		return &HeaderError{Name: h.Name, Err: ErrHeaderNewline}
+	}
+	// GMime folds at whitespace, a longer word stays on one line
+	words := strings.FieldsFunc(h.Value, func(r rune) bool {
+		return r == ' ' || r == '\t'
+	})
+	for i, word := range words {
+		length := len(word) + 1 // folding whitespace
+		if i == 0 {
+			length += len(h.Name) + len(": ")
+		}
+		if length > maxHeaderLine {
+			return &HeaderError{Name: h.Name, Err: ErrHeaderLineLength}
+		}
+	}
+	return nil
+}
+
+// headerField is a value which goes into a part header, e.g. a parameter
+type headerField struct {
+	name, value string
+}
+
+// validateFields returns *HeaderError for the first field with CR or LF
+func validateFields(fields []headerField) error {
+	for _, f := range fields {
+		if strings.ContainsAny(f.value, "\r\n") {
+			return &HeaderError{Name: f.name, Err: ErrHeaderNewline}
+		}
+	}
+	return nil
+}
+
+// validateAttachment checks fields of a which go into part headers, and its extra headers
+func validateAttachment(a *EmailAttachment) error {
+	err := validateFields([]headerField{
+		{"Content-Type", a.MimeType},
+		{"Content-Id", a.ContentID},
+		{"Content-Disposition", a.Disposition},
+		{"Content-Disposition", a.FileName},
+	})
+	if err != nil {
+		return err
+	}
+	if _, _, ok := splitMediaType(a.MimeType); a.MimeType != "" && !ok {
+		return ErrMediaType
+	}
+	for _, h := range a.Headers {
+		switch strings.ToLower(h.Name) {
+		case "content-type", "content-transfer-encoding", "content-disposition":
+			return &HeaderError{Name: h.Name, Err: ErrHeaderGenerated}
+		case "content-id":
+			if a.ContentID != "" {
+				return &HeaderError{Name: h.Name, Err: ErrHeaderGenerated}
+			}
+		}
+	}
+	return validateHeaders(a.Headers)
+}
+
+// validatePart checks parameters, disposition and file name of p, and its extra headers
+func validatePart(p *Part) error {
+	fields := []headerField{
+		{"Content-Disposition", p.disposition},
+		{"Content-Disposition", p.fileName},
+	}
+	for _, param := range p.params {
+		fields = append(fields, headerField{"Content-Type", param[0]}, headerField{"Content-Type", param[1]})
+	}
+	if err := validateFields(fields); err != nil {
+		return err
+	}
+	return validateHeaders(p.headers)
+}
+
+// validateBodyOptions checks charsets of bodies, they go into Content-Type
+func validateBodyOptions(options ...*BodyOptions) error {
+	for _, o := range options {
+		if o != nil {
+			if err := validateFields([]headerField{{"Content-Type", o.Charset}}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// splitMediaType splits type/subtype, ok is false if either is empty
+func splitMediaType(mediaType string) (string, string, bool) {
+	mimeSplit := strings.SplitN(mediaType, "/", 2)
+	if len(mimeSplit) != 2 || mimeSplit[0] == "" || mimeSplit[1] == "" {
+		return "", "", false
+	}
+	return mimeSplit[0], mimeSplit[1], true
+}
+
+// validateAddresses checks that addresses can't inject headers
+func validateAddresses(addresses []*EmailAddress) error {
+	for _, a := range addresses {
+		if strings.ContainsAny(a.Name, "\r\n") || strings.ContainsAny(a.Address, "\r\n") || strings.ContainsAny(a.Group, "\r\n") {
+			return &HeaderError{Name: addressHeaderName(a.AddressType), Err: ErrHeaderNewline}
+		}
+	}
+	return nil
+}
+
+func addressHeaderName(addressType AddressType) string {
+	switch addressType {
+	case AddressBCC:
+		return "Bcc"
+	case AddressResentBCC:
+		return "Resent-Bcc"
+	}
+	for _, h := range unencodedAddressHeaders {
+		if h.addressType == addressType {
+			return h.name
+		}
+	}
+	return ""
+}
+
+// validHeaderName checks field name, RFC 5322 section 3.6.8
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if name[i] < 33 || name[i] > 126 || name[i] == ':' {
+			return false
+		}
+	}
+	return true
+}
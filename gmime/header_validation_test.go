@@ -0,0 +1,61 @@
+package gmime
+
+import "testing"
+
+func TestValidatePart(t *testing.T) {
+	tests := []struct {
+		name string
+		part func(p *Part)
+		want string // HeaderError name, "" for no error
+	}{
+		{"valid", func(p *Part) {
+			p.SetParam("charset", "utf-8")
+			p.SetDisposition("attachment")
+			p.SetFileName("a.txt")
+		}, ""},
+		{"param value", func(p *Part) { p.SetParam("charset", "utf-8\r\nBcc: x@example.com") }, "Content-Type"},
+		{"param name", func(p *Part) { p.SetParam("charset\n", "utf-8") }, "Content-Type"},
+		{"disposition", func(p *Part) { p.SetDisposition("attachment\nX-Injected: 1") }, "Content-Disposition"},
+		{"file name", func(p *Part) { p.SetFileName("a.txt\r") }, "Content-Disposition"},
+		{"header", func(p *Part) { p.AppendHeader(&EmailHeader{Name: "X-Part", Value: "a\nb"}) }, "X-Part"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewLeafPart("text/plain")
+			tt.part(p)
+			checkHeaderError(t, validatePart(p), tt.want)
+		})
+	}
+}
+
+func TestValidateBodyOptions(t *testing.T) {
+	checkHeaderError(t, validateBodyOptions(nil, &BodyOptions{Charset: "iso-8859-1"}), "")
+	checkHeaderError(t, validateBodyOptions(nil, &BodyOptions{Charset: "utf-8\r\nX-Injected: 1"}), "Content-Type")
+}
+
+func TestValidateAttachmentMediaType(t *testing.T) {
+	for _, mimeType := range []string{"pdf", "application/", "/pdf"} {
+		if err := validateAttachment(&EmailAttachment{MimeType: mimeType}); err != ErrMediaType {
+			t.Errorf("%q: got %v, want ErrMediaType", mimeType, err)
+		}
+	}
+	for _, mimeType := range []string{"", "application/pdf"} {
+		if err := validateAttachment(&EmailAttachment{MimeType: mimeType}); err != nil {
+			t.Errorf("%q: got %v", mimeType, err)
+		}
+	}
+}
+
+// checkHeaderError checks err is *HeaderError with ErrHeaderNewline for header name, or nil if name is empty
+func checkHeaderError(t *testing.T, err error, name string) {
+	t.Helper()
+	if name == "" {
+		if err != nil {
+			t.Errorf("got %v", err)
+		}
+		return
+	}
+	if he, ok := err.(*HeaderError); !ok || he.Name != name || he.Err != ErrHeaderNewline {
+		t.Errorf("got %v, want %s HeaderError", err, name)
+	}
+}
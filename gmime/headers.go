@@ -38,44 +38,196 @@ import (
 	"unsafe"
 )
 
-func injectHeaders(obj *C.GMimeObject, headers []*EmailHeader, addresses []*EmailAddress) {
+// injectHeaders adds headers and addresses to message obj, headers are appended in their order
+// after standard message headers. unencoded writes UTF-8 as is instead of RFC 2047 encoding it
+func injectHeaders(obj *C.GMimeObject, headers []*EmailHeader, addresses []*EmailAddress, unencoded bool) {
 	headerList := C.g_mime_object_get_header_list(anyToGMimeObject(unsafe.Pointer(obj)))
+	lists := addressLists(addresses)
+	defer func() {
+		for _, list := range lists {
+			C.g_object_unref(list)
+		}
+	}()
+
+	// GMime keeps one writer per name, so every value of a name written by raw writer is folded here
+	rawNames := rawWriterNames(headers)
+	if unencoded {
+		// message keeps address headers in sync with its lists and its writers encode them,
+		// so unencoded address values go into the header list directly and are written by raw writer
+		for _, h := range unencodedAddressHeaders {
+			if _, ok := lists[h.addressType]; ok {
+				rawNames[strings.ToLower(h.name)] = true
+			}
+		}
+	}
+
 	for _, h := range headers {
-		name := C.CString(h.Name)   // needs free
-		value := C.CString(h.Value) // needs free
-		if h.Raw {
+		name := C.CString(h.Name) // needs free
+		switch {
+		case rawNames[strings.ToLower(h.Name)]:
+			// folded here and written as is, so EncodedHeaders have the same lines as the message
+			value := C.CString(rawHeaderValue(h, unencoded)) // needs free
 			C.g_mime_header_list_register_writer(headerList, name, (C.GMimeHeaderWriter)(unsafe.Pointer(C.raw_header_writer)))
-			C.g_mime_object_prepend_header(anyToGMimeObject(unsafe.Pointer(obj)), name, value)
-		} else {
+			C.g_mime_object_append_header(anyToGMimeObject(unsafe.Pointer(obj)), name, value)
+			C.free(unsafe.Pointer(value))
+		case unencoded:
+			value := C.CString(h.Value) // needs free
+			C.g_mime_object_append_header(anyToGMimeObject(unsafe.Pointer(obj)), name, value)
+			C.free(unsafe.Pointer(value))
+		default:
+			value := C.CString(h.Value)                              // needs free
 			encodedValue := C.g_mime_utils_header_encode_text(value) // needs g_free
-			//TODO: support append/prepend
-			C.g_mime_object_prepend_header(anyToGMimeObject(unsafe.Pointer(obj)), name, encodedValue)
+			C.g_mime_object_append_header(anyToGMimeObject(unsafe.Pointer(obj)), name, encodedValue)
 			C.g_free(C.gpointer(encodedValue))
+			C.free(unsafe.Pointer(value))
 		}
-
 		C.free(unsafe.Pointer(name))
-		C.free(unsafe.Pointer(value))
+	}
+
+	// address headers whose name is written by raw writer are folded here, the raw writer doesn't fold
+	encode := C.gboolean(C.TRUE)
+	if unencoded {
+		encode = C.FALSE
+	}
+	folded := map[AddressType]bool{}
+	for _, h := range unencodedAddressHeaders {
+		if list, ok := lists[h.addressType]; ok && rawNames[strings.ToLower(h.name)] {
+			name := C.CString(h.name)                                                       // needs free
+			value := C.internet_address_list_to_string(list, encode)                        // needs g_free
+			foldedValue := C.CString(foldHeader(h.name, C.GoString(value), foldHeaderLine)) // needs free
+			C.g_mime_header_list_register_writer(headerList, name, (C.GMimeHeaderWriter)(unsafe.Pointer(C.raw_header_writer)))
+			C.g_mime_header_list_set(headerList, name, foldedValue)
+			C.free(unsafe.Pointer(name))
+			C.free(unsafe.Pointer(foldedValue))
+			C.g_free(C.gpointer(value))
+			folded[h.addressType] = true
+		}
 	}
 
 	message := (*C.GMimeMessage)(unsafe.Pointer(obj))
+	// names are quoted and encoded by GMime the same way as for To and CC
+	if list, ok := lists[AddressFrom]; ok && !folded[AddressFrom] {
+		value := C.internet_address_list_to_string(list, C.TRUE) // needs g_free
+		C.g_mime_message_set_sender(message, value)
+		C.g_free(C.gpointer(value))
+	}
+	if list, ok := lists[AddressReplyTo]; ok && !folded[AddressReplyTo] {
+		value := C.internet_address_list_to_string(list, C.TRUE) // needs g_free
+		C.g_mime_message_set_reply_to(message, value)
+		C.g_free(C.gpointer(value))
+	}
+	for _, addressType := range []AddressType{AddressTo, AddressCC} {
+		if list, ok := lists[addressType]; ok && !folded[addressType] {
+			// recipients list is owned by message, it updates the header on change
+			C.internet_address_list_append(C.g_mime_message_get_recipients(message, (C.GMimeRecipientType)(addressType)), list)
+		}
+	}
+	for _, h := range addressHeaders {
+		if list, ok := lists[h.addressType]; ok && !folded[h.addressType] {
+			name := C.CString(h.name)                                // needs free
+			value := C.internet_address_list_to_string(list, C.TRUE) // needs g_free
+			C.g_mime_object_set_header(obj, name, value)
+			C.free(unsafe.Pointer(name))
+			C.g_free(C.gpointer(value))
+		}
+	}
+}
+
+// rawHeaderValue returns value of h for raw writer: FoldingRaw value as is, others RFC 2047 encoded
+// unless unencoded and folded at whitespace, FoldingHardLimit at 998 characters and the rest at 78
+func rawHeaderValue(h *EmailHeader, unencoded bool) string {
+	policy := h.folding()
+	if policy == FoldingRaw {
+		return h.Value
+	}
+	value := h.Value
+	if !unencoded {
+		cValue := C.CString(h.Value)                              // needs free
+		encodedValue := C.g_mime_utils_header_encode_text(cValue) // needs g_free
+		value = C.GoString(encodedValue)
+		C.g_free(C.gpointer(encodedValue))
+		C.free(unsafe.Pointer(cValue))
+	}
+	width := foldHeaderLine
+	if policy == FoldingHardLimit {
+		width = maxHeaderLine
+	}
+	return foldHeader(h.Name, value, width)
+}
+
+// addressHeaders are address fields written as plain headers, in the order they go.
+// BCC and Resent-BCC are not written
+var addressHeaders = []struct {
+	addressType AddressType
+	name        string
+}{
+	{AddressSender, "Sender"},
+	{AddressResentFrom, "Resent-From"},
+	{AddressResentSender, "Resent-Sender"},
+	{AddressResentTo, "Resent-To"},
+	{AddressResentCC, "Resent-Cc"},
+}
+
+var unencodedAddressHeaders = append([]struct {
+	addressType AddressType
+	name        string
+}{
+	{AddressFrom, "From"},
+	{AddressReplyTo, "Reply-To"},
+	{AddressTo, "To"},
+	{AddressCC, "Cc"},
+}, addressHeaders...)
+
+// addressLists returns InternetAddressList of every address type,
+// addresses of one type with the same Group go into one group
+// caller responsible for unref of lists
+func addressLists(addresses []*EmailAddress) map[AddressType]*C.InternetAddressList {
+	type groupKey struct {
+		addressType AddressType
+		name        string
+	}
+	lists := map[AddressType]*C.InternetAddressList{}
+	groups := map[groupKey]*C.InternetAddressGroup{}
 	for _, a := range addresses {
-		switch a.AddressType {
-		case AddressTo, AddressCC:
-			name := C.CString(a.Name)       // needs free
-			address := C.CString(a.Address) // needs free
-			C.g_mime_message_add_recipient(message, (C.GMimeRecipientType)(a.AddressType), name, address)
+		list, ok := lists[a.AddressType]
+		if !ok {
+			list = C.internet_address_list_new() // caller to unref
+			lists[a.AddressType] = list
+		}
+
+		var mailbox *C.InternetAddress
+		if a.Address != "" {
+			name := C.CString(a.Name)                               // needs free
+			address := C.CString(a.Address)                         // needs free
+			mailbox = C.internet_address_mailbox_new(name, address) // needs unref
 			C.free(unsafe.Pointer(name))
 			C.free(unsafe.Pointer(address))
-		case AddressFrom:
-			addr := C.CString(a.Name + " " + "<" + a.Address + ">")
-			C.g_mime_message_set_sender(message, addr)
-			C.free(unsafe.Pointer(addr))
-		case AddressReplyTo:
-			addr := C.CString(a.Name + " " + "<" + a.Address + ">")
-			C.g_mime_message_set_reply_to(message, addr)
-			C.free(unsafe.Pointer(addr))
+		}
+
+		if a.Group == "" {
+			if mailbox != nil {
+				C.internet_address_list_add(list, mailbox)
+				C.g_object_unref(mailbox) // unref, list keeps it
+			}
+			continue
+		}
+		key := groupKey{a.AddressType, a.Group}
+		group, ok := groups[key]
+		if !ok {
+			name := C.CString(a.Group)                         // needs free
+			groupAddress := C.internet_address_group_new(name) // needs unref
+			C.free(unsafe.Pointer(name))
+			C.internet_address_list_add(list, groupAddress)
+			C.g_object_unref(groupAddress) // unref, list keeps it
+			group = (*C.InternetAddressGroup)(unsafe.Pointer(groupAddress))
+			groups[key] = group
+		}
+		if mailbox != nil {
+			C.internet_address_group_add_member(group, mailbox)
+			C.g_object_unref(mailbox) // unref, group keeps it
 		}
 	}
+	return lists
 }
 
 func encodedHeadersFromGmime(obj *C.GMimeObject) []*EncodedHeader {
@@ -0,0 +1,45 @@
+package gmime
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHeadersRoundTrip(t *testing.T) {
+	m := NewMessage()
+	m.AddAddress(&EmailAddress{AddressType: AddressFrom, Address: "sender@example.com"})
+	m.SetText([]byte("text"))
+	m.AppendHeader(&EmailHeader{Name: "X-A", Value: "1"})
+	m.AppendHeader(&EmailHeader{Name: "X-B", Value: "1"})
+	m.AppendHeader(&EmailHeader{Name: "x-a", Value: "2"})
+	m.AppendHeader(&EmailHeader{Name: "X-C", Value: "1"})
+	m.PrependHeader(&EmailHeader{Name: "X-First", Value: "1"})
+
+	if got := m.GetHeaders("X-A"); len(got) != 2 || got[0].Value != "1" || got[1].Value != "2" {
+		t.Errorf("GetHeaders before SetHeader: %+v", got)
+	}
+	m.SetHeader(&EmailHeader{Name: "X-A", Value: "replaced"})
+	m.RemoveHeader("x-c")
+	m.AppendHeader(&EmailHeader{Name: "X-D", Value: "1"})
+	m.SetHeader(&EmailHeader{Name: "X-E", Value: "set"})
+	m.RemoveHeader("X-Missing")
+
+	if got := m.GetHeaders("x-a"); len(got) != 1 || got[0].Value != "replaced" {
+		t.Errorf("GetHeaders after SetHeader: %+v", got)
+	}
+	if got := m.GetHeaders("X-C"); len(got) != 0 {
+		t.Errorf("GetHeaders after RemoveHeader: %+v", got)
+	}
+
+	_, parsed := exportParsed(t, m)
+	var got []string
+	for _, h := range parsed.Headers {
+		if strings.HasPrefix(strings.ToLower(h.Name), "x-") {
+			got = append(got, h.Name+": "+h.Value)
+		}
+	}
+	want := []string{"X-First: 1", "X-A: replaced", "X-B: 1", "X-D: 1", "X-E: set"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("exported headers\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
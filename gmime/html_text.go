@@ -0,0 +1,259 @@
+package gmime
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// HTMLToText converts html body into readable plain text: links become numbered footnotes,
+// lists get bullets or numbers, table rows go on separate lines and entities are decoded
+func HTMLToText(body []byte) []byte {
+	t := &htmlText{linkIndex: map[string]int{}}
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			// io.EOF or broken markup, either way what was converted so far is the result
+			break
+		}
+		token := z.Token()
+		switch tt {
+		case html.TextToken:
+			if t.skip == 0 {
+				t.text(token.Data)
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			t.start(token, tt == html.SelfClosingTagToken)
+		case html.EndTagToken:
+			t.end(token)
+		}
+	}
+	return t.result()
+}
+
+type htmlList struct {
+	ordered bool
+	n       int
+}
+
+type htmlLink struct {
+	href  string
+	start int // out length when the anchor started
+}
+
+type htmlText struct {
+	out      bytes.Buffer
+	newlines int  // line breaks to write before the next text
+	space    bool // whitespace to write before the next text
+	pre      int
+	skip     int // inside head, script, style
+	lists    []htmlList
+	cells    int // cells written in the current table row
+
+	links     []htmlLink
+	footnotes []string
+	linkIndex map[string]int
+}
+
+func (t *htmlText) block(newlines int) {
+	if newlines > t.newlines {
+		t.newlines = newlines
+	}
+	t.space = false
+}
+
+func (t *htmlText) write(s string) {
+	if t.out.Len() > 0 {
+		if t.newlines > 0 {
+			t.out.WriteString(strings.Repeat("\n", t.newlines))
+		} else if t.space {
+			t.out.WriteByte(' ')
+		}
+	}
+	t.newlines = 0
+	t.space = false
+	t.out.WriteString(s)
+}
+
+func (t *htmlText) text(data string) {
+	data = strings.Replace(data, "\u00a0", " ", -1) // &nbsp;
+	if t.pre > 0 {
+		t.write(data)
+		return
+	}
+	words := strings.Fields(data)
+	if len(words) == 0 {
+		if data != "" {
+			t.space = true
+		}
+		return
+	}
+	if isHTMLSpace(data[0]) {
+		t.space = true
+	}
+	t.write(strings.Join(words, " "))
+	if isHTMLSpace(data[len(data)-1]) {
+		t.space = true
+	}
+}
+
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func (t *htmlText) start(token html.Token, selfClosing bool) {
+	switch token.DataAtom {
+	case atom.Head, atom.Script, atom.Style, atom.Title, atom.Template:
+		if !selfClosing {
+			t.skip++
+		}
+	case atom.Br:
+		if t.out.Len() > 0 {
+			t.out.WriteString(strings.Repeat("\n", t.newlines+1))
+		}
+		t.newlines = 0
+		t.space = false
+	case atom.Hr:
+		t.block(2)
+		t.write(strings.Repeat("-", 40))
+		t.block(2)
+	case atom.P, atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Blockquote, atom.Table:
+		t.block(2)
+	case atom.Pre:
+		t.block(2)
+		t.pre++
+	case atom.Div, atom.Tr, atom.Section, atom.Article, atom.Header, atom.Footer, atom.Center, atom.Dl, atom.Dt, atom.Dd:
+		t.block(1)
+		t.cells = 0
+	case atom.Td, atom.Th:
+		if t.cells > 0 {
+			t.space = true
+		}
+		t.cells++
+	case atom.Ul, atom.Ol:
+		t.block(1)
+		t.lists = append(t.lists, htmlList{ordered: token.DataAtom == atom.Ol})
+	case atom.Li:
+		t.block(1)
+		bullet := "* "
+		if len(t.lists) > 0 {
+			list := &t.lists[len(t.lists)-1]
+			list.n++
+			if list.ordered {
+				bullet = strconv.Itoa(list.n) + ". "
+			}
+		}
+		indent := ""
+		if len(t.lists) > 1 {
+			indent = strings.Repeat("  ", len(t.lists)-1)
+		}
+		t.write(indent + bullet)
+	case atom.Img:
+		if alt := htmlAttr(token, "alt"); strings.TrimSpace(alt) != "" {
+			t.write(strings.TrimSpace(alt))
+		}
+	case atom.A:
+		if !selfClosing {
+			t.links = append(t.links, htmlLink{href: strings.TrimSpace(htmlAttr(token, "href")), start: t.out.Len()})
+		}
+	}
+}
+
+func (t *htmlText) end(token html.Token) {
+	switch token.DataAtom {
+	case atom.Head, atom.Script, atom.Style, atom.Title, atom.Template:
+		if t.skip > 0 {
+			t.skip--
+		}
+	case atom.P, atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Blockquote, atom.Table:
+		t.block(2)
+	case atom.Pre:
+		if t.pre > 0 {
+			t.pre--
+		}
+		t.block(2)
+	case atom.Div, atom.Tr, atom.Section, atom.Article, atom.Header, atom.Footer, atom.Center, atom.Dl, atom.Dt, atom.Dd, atom.Li:
+		t.block(1)
+	case atom.Ul, atom.Ol:
+		if len(t.lists) > 0 {
+			t.lists = t.lists[:len(t.lists)-1]
+		}
+		t.block(1)
+	case atom.A:
+		if len(t.links) == 0 {
+			return
+		}
+		link := t.links[len(t.links)-1]
+		t.links = t.links[:len(t.links)-1]
+		t.footnote(link)
+	}
+}
+
+// footnote adds [n] after anchor text unless the text already shows the URL
+func (t *htmlText) footnote(link htmlLink) {
+	href := link.href
+	lower := strings.ToLower(href)
+	if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(lower, "javascript:") {
+		return
+	}
+	text := ""
+	if link.start <= t.out.Len() {
+		text = strings.TrimSpace(string(t.out.Bytes()[link.start:]))
+	}
+	if text == href || "mailto:"+text == href || text == "" {
+		return
+	}
+	n, ok := t.linkIndex[href]
+	if !ok {
+		t.footnotes = append(t.footnotes, href)
+		n = len(t.footnotes)
+		t.linkIndex[href] = n
+	}
+	t.out.WriteString(" [" + strconv.Itoa(n) + "]")
+}
+
+func (t *htmlText) result() []byte {
+	lines := strings.Split(t.out.String(), "\n")
+	var result bytes.Buffer
+	blank := 0
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t")
+		if line == "" {
+			blank++
+			continue
+		}
+		if result.Len() > 0 {
+			if blank > 0 {
+				result.WriteString("\n\n")
+			} else {
+				result.WriteString("\n")
+			}
+		}
+		blank = 0
+		result.WriteString(line)
+	}
+	if len(t.footnotes) > 0 {
+		if result.Len() > 0 {
+			result.WriteString("\n\n")
+		}
+		for i, href := range t.footnotes {
+			result.WriteString("[" + strconv.Itoa(i+1) + "] " + href + "\n")
+		}
+	} else if result.Len() > 0 {
+		result.WriteString("\n")
+	}
+	return result.Bytes()
+}
+
+func htmlAttr(token html.Token, name string) string {
+	for _, a := range token.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
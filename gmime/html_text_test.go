@@ -0,0 +1,74 @@
+package gmime
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHTMLToText(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "links become footnotes",
+			html: `<p>Read <a href="https://example.com/a">the post</a> and <a href="https://example.com/b">docs</a>,
+				then <a href="https://example.com/a">the post</a> again.</p>`,
+			want: "Read the post [1] and docs [2], then the post [1] again.\n\n" +
+				"[1] https://example.com/a\n[2] https://example.com/b\n",
+		},
+		{
+			name: "links without footnotes",
+			html: `<a href="https://example.com">https://example.com</a> <a href="mailto:a@example.com">a@example.com</a> <a href="#top">top</a>`,
+			want: "https://example.com a@example.com top\n",
+		},
+		{
+			name: "lists",
+			html: `<ul><li>one</li><li>two<ol><li>first</li><li>second</li></ol></li></ul>`,
+			want: "* one\n* two\n  1. first\n  2. second\n",
+		},
+		{
+			name: "table",
+			html: `<table><tr><th>Item</th><th>Price</th></tr><tr><td>Tea</td><td>3</td></tr></table><p>Total</p>`,
+			want: "Item Price\nTea 3\n\nTotal\n",
+		},
+		{
+			name: "entities",
+			html: `<p>Caf&eacute; &amp; cr&#232;me&nbsp;br&ucirc;l&eacute; &lt;3</p>`,
+			want: "Café & crème brûlé <3\n",
+		},
+		{
+			name: "whitespace collapsing",
+			html: "<head><title>Title</title><style>p {}</style></head><body>\n  <p>  many \t\n spaces  </p>\n\n\n<div>next<br>line</div><pre>  kept\n    as is</pre></body>",
+			want: "many spaces\n\nnext\nline\n\n  kept\n    as is\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(HTMLToText([]byte(tt.html))); got != tt.want {
+				t.Errorf("got\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAutoTextCharset(t *testing.T) {
+	m := NewMessage()
+	m.SetHTMLWithOptions([]byte("<p>Gr\xfc\xdfe &eacute;</p>"), &BodyOptions{Charset: "iso-8859-1"})
+	m.SetAutoText(true)
+	data, err := m.Export()
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	parsed, err := ParseBytes(data)
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+	if got := strings.TrimRight(string(parsed.Text), "\n"); got != "Grüße é" {
+		t.Errorf("Text %q, want %q", got, "Grüße é")
+	}
+	if got := parsed.Root.Children[0].Params["charset"]; got != "utf-8" {
+		t.Errorf("text charset %q, want utf-8", got)
+	}
+}
@@ -0,0 +1,110 @@
+package gmime
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+var ErrNonASCIILocalPart = errors.New("Local part of address is not ASCII, address can't be downgraded")
+
+// AddressMode is how exported messages write non-ASCII addresses and header values
+type AddressMode int
+
+const (
+	// AddressModeDefault writes addresses as given, display names and header values RFC 2047 encoded
+	AddressModeDefault AddressMode = iota
+	// AddressModeSMTPUTF8 writes addresses, display names and header values as UTF-8 without
+	// RFC 2047 encoding (RFC 6532), for relays which announce SMTPUTF8
+	AddressModeSMTPUTF8
+	// AddressModeASCII converts domains to Punycode, export fails with *AddressDowngradeError
+	// if a local part is not ASCII
+	AddressModeASCII
+)
+
+// SetAddressMode sets how exports write non-ASCII addresses, AddressModeDefault if not set
+func (m *Message) SetAddressMode(mode AddressMode) {
+	m.addressMode = mode
+}
+
+// AddressDowngradeError lists addresses which can't be written in ASCII
+type AddressDowngradeError struct {
+	Addresses []*EmailAddress
+}
+
+func (e *AddressDowngradeError) Error() string {
+	addresses := make([]string, 0, len(e.Addresses))
+	for _, a := range e.Addresses {
+		addresses = append(addresses, a.Address)
+	}
+	return "Addresses can't be converted to ASCII: " + strings.Join(addresses, ", ")
+}
+
+// UnicodeAddress returns Address with Punycode domain converted to Unicode
+func (a *EmailAddress) UnicodeAddress() string {
+	local, domain := splitAddress(a.Address)
+	if domain == "" {
+		return a.Address
+	}
+	unicodeDomain, err := idna.ToUnicode(domain)
+	if err != nil {
+		return a.Address
+	}
+	return local + "@" + unicodeDomain
+}
+
+// ASCIIAddress returns Address with domain converted to Punycode,
+// ErrNonASCIILocalPart if local part is not ASCII
+func (a *EmailAddress) ASCIIAddress() (string, error) {
+	local, domain := splitAddress(a.Address)
+	if !isASCII(local) {
+		return "", ErrNonASCIILocalPart
+	}
+	if domain == "" {
+		return a.Address, nil
+	}
+	asciiDomain, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return "", err
+	}
+	return local + "@" + asciiDomain, nil
+}
+
+// asciiAddresses returns copies of addresses with ASCIIAddress, error lists all addresses which failed
+func asciiAddresses(addresses []*EmailAddress) ([]*EmailAddress, error) {
+	var result, failed []*EmailAddress
+	for _, a := range addresses {
+		address, err := a.ASCIIAddress()
+		if err != nil {
+			failed = append(failed, a)
+			continue
+		}
+		converted := *a
+		converted.Address = address
+		result = append(result, &converted)
+	}
+	if len(failed) > 0 {
+		return nil, &AddressDowngradeError{Addresses: failed}
+	}
+	return result, nil
+}
+
+// splitAddress splits address at the last @, domain is empty if there is no @
+func splitAddress(address string) (string, string) {
+	at := strings.LastIndexByte(address, '@')
+	if at < 0 {
+		return address, ""
+	}
+	return address[:at], address[at+1:]
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
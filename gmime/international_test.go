@@ -0,0 +1,96 @@
+package gmime
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestASCIIAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		want    string
+		err     error
+	}{
+		{"user@example.com", "user@example.com", nil},
+		{"user@bücher.example", "user@xn--bcher-kva.example", nil},
+		{"user@ÉCOLE.example", "user@xn--cole-9oa.example", nil},
+		{"jürgen@example.com", "", ErrNonASCIILocalPart},
+		{"local", "local", nil},
+	}
+	for _, tt := range tests {
+		got, err := (&EmailAddress{Address: tt.address}).ASCIIAddress()
+		if got != tt.want || err != tt.err {
+			t.Errorf("%s: got %q, %v, want %q, %v", tt.address, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestAddressModeSMTPUTF8(t *testing.T) {
+	m := NewMessage()
+	m.SetAddressMode(AddressModeSMTPUTF8)
+	m.AddAddress(&EmailAddress{AddressType: AddressFrom, Name: "Jürgen Müller", Address: "jürgen@müller.example"})
+	for _, name := range []string{"Zoë", "Renée", "Björn", "Łukasz", "Chloé", "Søren"} {
+		m.AddAddress(&EmailAddress{AddressType: AddressTo, Name: name, Address: strings.ToLower(name) + "@bücher.example"})
+	}
+	m.SetHeader(&EmailHeader{Name: "Subject", Value: strings.Repeat("Grüße aus München ", 6)})
+	m.SetText([]byte("text"))
+	data, parsed := exportParsed(t, m)
+
+	header := data[:bytes.Index(data, []byte("\n\n"))]
+	if bytes.Contains(header, []byte("=?")) {
+		t.Errorf("header is RFC 2047 encoded:\n%s", header)
+	}
+	for _, want := range []string{"Jürgen Müller", "jürgen@müller.example", "søren@bücher.example", "Grüße aus München"} {
+		if !bytes.Contains(header, []byte(want)) {
+			t.Errorf("header has no raw %q:\n%s", want, header)
+		}
+	}
+	for _, line := range strings.Split(string(header), "\n") {
+		if len(line) > foldHeaderLine {
+			t.Errorf("line is %d bytes: %q", len(line), line)
+		}
+	}
+	if got := parsed.Header("Subject"); got != strings.TrimSpace(strings.Repeat("Grüße aus München ", 6)) {
+		t.Errorf("Subject %q", got)
+	}
+}
+
+func TestAddressModeASCII(t *testing.T) {
+	m := NewMessage()
+	m.SetAddressMode(AddressModeASCII)
+	m.AddAddress(&EmailAddress{AddressType: AddressFrom, Name: "Jürgen", Address: "juergen@müller.example"})
+	m.AddAddress(&EmailAddress{AddressType: AddressTo, Address: "user@bücher.example"})
+	m.SetText([]byte("text"))
+	data, parsed := exportParsed(t, m)
+
+	for _, want := range []string{"juergen@xn--mller-kva.example", "user@xn--bcher-kva.example"} {
+		if !bytes.Contains(data, []byte(want)) {
+			t.Errorf("export has no %s:\n%s", want, data)
+		}
+	}
+	if !isASCII(string(data)) {
+		t.Errorf("export is not ASCII:\n%s", data)
+	}
+	if parsed.Addresses[0].Name != "Jürgen" {
+		t.Errorf("From name %q", parsed.Addresses[0].Name)
+	}
+}
+
+func TestAddressModeASCIIDowngradeError(t *testing.T) {
+	m := NewMessage()
+	m.SetAddressMode(AddressModeASCII)
+	m.AddAddress(&EmailAddress{AddressType: AddressFrom, Address: "sender@example.com"})
+	m.AddAddress(&EmailAddress{AddressType: AddressTo, Address: "jürgen@example.com"})
+	m.AddAddress(&EmailAddress{AddressType: AddressTo, Address: "user@bücher.example"})
+	m.AddAddress(&EmailAddress{AddressType: AddressCC, Address: "zoë@bücher.example"})
+	m.SetText([]byte("text"))
+	_, err := m.Export()
+	downgrade, ok := err.(*AddressDowngradeError)
+	if !ok {
+		t.Fatalf("got %v, want *AddressDowngradeError", err)
+	}
+	if len(downgrade.Addresses) != 2 || downgrade.Addresses[0].Address != "jürgen@example.com" || downgrade.Addresses[1].Address != "zoë@bücher.example" {
+		t.Errorf("addresses %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package gmime
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// LinkRewriter returns URL which replaces url of index-th rewritten link of html body, index starts from 0
+type LinkRewriter func(index int, url string) string
+
+// SetLinkRewriter makes exports rewrite href of every anchor in html body through r,
+// e.g. for click tracking. mailto: links, #anchors, links marked with
+// clicktracking="off" attribute and links to URLs of List-Unsubscribe header are left as is,
+// other unsubscribe links need clicktracking="off". Same URLs in text body are rewritten too,
+// each to the URL of its first link in html. nil turns it off
+func (m *Message) SetLinkRewriter(r LinkRewriter) {
+	m.linkRewriter = r
+}
+
+// rewriteLinks returns html with rewritten hrefs and map from original to rewritten URLs.
+// Links to skip URLs are left as is. Only href values are replaced, the rest of html stays byte to byte the same
+func rewriteLinks(body []byte, rewrite LinkRewriter, skip map[string]bool) ([]byte, map[string]string) {
+	var result bytes.Buffer
+	urls := map[string]string{}
+	index := 0
+	offset := 0 // body offset of the current token
+	copied := 0 // body bytes already in result
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		raw := z.Raw()
+		start := offset
+		offset += len(raw)
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+		token := z.Token()
+		if token.DataAtom != atom.A && token.DataAtom != atom.Area {
+			continue
+		}
+		url, ok := trackedHref(token)
+		if !ok || skip[url] {
+			continue
+		}
+		valueStart, valueEnd := hrefValueSpan(raw)
+		if valueStart < 0 {
+			continue
+		}
+		rewritten := rewrite(index, url)
+		index++
+		if _, ok := urls[url]; !ok {
+			urls[url] = rewritten
+		}
+		result.Write(body[copied : start+valueStart])
+		result.WriteString(`"` + html.EscapeString(rewritten) + `"`)
+		copied = start + valueEnd
+	}
+	if copied == 0 {
+		return body, urls
+	}
+	result.Write(body[copied:])
+	return result.Bytes(), urls
+}
+
+// trackedHref returns href of anchor token if it should be rewritten
+func trackedHref(token html.Token) (string, bool) {
+	href, found := "", false
+	for _, a := range token.Attr {
+		switch strings.ToLower(a.Key) {
+		case "href":
+			if !found {
+				href, found = strings.TrimSpace(a.Val), true
+			}
+		case "clicktracking":
+			if strings.EqualFold(a.Val, "off") {
+				return "", false
+			}
+		}
+	}
+	lower := strings.ToLower(href)
+	if !found || href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(lower, "mailto:") {
+		return "", false
+	}
+	return href, true
+}
+
+// listUnsubscribeURLs returns URLs in angle brackets of List-Unsubscribe headers
+func listUnsubscribeURLs(headers []*EmailHeader) map[string]bool {
+	urls := map[string]bool{}
+	for _, h := range headers {
+		if !strings.EqualFold(h.Name, "List-Unsubscribe") {
+			continue
+		}
+		for _, field := range strings.Split(h.Value, ",") {
+			field = strings.TrimSpace(field)
+			if strings.HasPrefix(field, "<") && strings.HasSuffix(field, ">") {
+				urls[strings.TrimSpace(field[1:len(field)-1])] = true
+			}
+		}
+	}
+	return urls
+}
+
+// hrefValueSpan returns position of href attribute value in raw start tag, including quotes,
+// -1 if there is no href value
+func hrefValueSpan(raw []byte) (int, int) {
+	isSpace := func(c byte) bool {
+		return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+	}
+	i := 1 // skip '<'
+	for i < len(raw) && !isSpace(raw[i]) && raw[i] != '>' && raw[i] != '/' {
+		i++
+	}
+	for i < len(raw) {
+		for i < len(raw) && (isSpace(raw[i]) || raw[i] == '/') {
+			i++
+		}
+		if i >= len(raw) || raw[i] == '>' {
+			break
+		}
+		nameStart := i
+		for i < len(raw) && !isSpace(raw[i]) && raw[i] != '=' && raw[i] != '>' && raw[i] != '/' {
+			i++
+		}
+		name := string(raw[nameStart:i])
+		for i < len(raw) && isSpace(raw[i]) {
+			i++
+		}
+		if i >= len(raw) || raw[i] != '=' {
+			continue // attribute without value
+		}
+		i++
+		for i < len(raw) && isSpace(raw[i]) {
+			i++
+		}
+		valueStart := i
+		if i < len(raw) && (raw[i] == '"' || raw[i] == '\'') {
+			quote := raw[i]
+			i++
+			for i < len(raw) && raw[i] != quote {
+				i++
+			}
+			if i < len(raw) {
+				i++
+			}
+		} else {
+			for i < len(raw) && !isSpace(raw[i]) && raw[i] != '>' {
+				i++
+			}
+		}
+		if strings.EqualFold(name, "href") {
+			return valueStart, i
+		}
+	}
+	return -1, -1
+}
+
+var textURLPattern = regexp.MustCompile(`(?i)\b(?:https?|ftp)://[^\s<>"]+`)
+
+// rewriteTextLinks replaces URLs of text which were rewritten in html
+func rewriteTextLinks(text []byte, urls map[string]string) []byte {
+	if len(urls) == 0 {
+		return text
+	}
+	return textURLPattern.ReplaceAllFunc(text, func(url []byte) []byte {
+		// punctuation after a URL most likely ends the sentence
+		trimmed := bytes.TrimRight(url, ".,;:!?)]'")
+		for len(trimmed) <= len(url) {
+			if rewritten, ok := urls[string(trimmed)]; ok {
+				return append([]byte(rewritten), url[len(trimmed):]...)
+			}
+			if len(trimmed) == len(url) {
+				break
+			}
+			trimmed = url[:len(trimmed)+1]
+		}
+		return url
+	})
+}
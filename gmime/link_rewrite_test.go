@@ -0,0 +1,75 @@
+package gmime
+
+import (
+	"strconv"
+	"testing"
+)
+
+// testRewriter returns https://t.example/<index>
+func testRewriter(index int, url string) string {
+	return "https://t.example/" + strconv.Itoa(index)
+}
+
+func TestRewriteLinks(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		skip map[string]bool
+		want string
+	}{
+		{
+			name: "double quoted",
+			html: `<a class="x" href="https://example.com/a?b=1&amp;c=2">A</a>`,
+			want: `<a class="x" href="https://t.example/0">A</a>`,
+		},
+		{
+			name: "single quoted",
+			html: `<a href='https://example.com/a' title="t">A</a>`,
+			want: `<a href="https://t.example/0" title="t">A</a>`,
+		},
+		{
+			name: "unquoted",
+			html: `<A HREF=https://example.com/a>A</A>`,
+			want: `<A HREF="https://t.example/0">A</A>`,
+		},
+		{
+			name: "clicktracking off",
+			html: `<a clicktracking="off" href="https://example.com/unsubscribe">U</a> <a href="https://example.com/a">A</a>`,
+			want: `<a clicktracking="off" href="https://example.com/unsubscribe">U</a> <a href="https://t.example/0">A</a>`,
+		},
+		{
+			name: "mailto and anchors",
+			html: `<a href="mailto:a@example.com">M</a><a href="#top">T</a>`,
+			want: `<a href="mailto:a@example.com">M</a><a href="#top">T</a>`,
+		},
+		{
+			name: "repeated url",
+			html: `<a href="https://example.com/a">1</a><a href="https://example.com/a">2</a>`,
+			want: `<a href="https://t.example/0">1</a><a href="https://t.example/1">2</a>`,
+		},
+		{
+			name: "list unsubscribe url",
+			html: `<a href="https://example.com/u">U</a><a href="https://example.com/a">A</a>`,
+			skip: listUnsubscribeURLs([]*EmailHeader{{Name: "List-Unsubscribe", Value: "<mailto:u@example.com>, <https://example.com/u>"}}),
+			want: `<a href="https://example.com/u">U</a><a href="https://t.example/0">A</a>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := rewriteLinks([]byte(tt.html), testRewriter, tt.skip)
+			if string(got) != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRewriteTextLinks(t *testing.T) {
+	html := `<a href="https://example.com/a">A</a> <a href="https://example.com/b">B</a> <a href="https://example.com/a">A</a>`
+	_, urls := rewriteLinks([]byte(html), testRewriter, nil)
+	text := "See https://example.com/a. Or (https://example.com/b), not https://example.com/c!"
+	want := "See https://t.example/0. Or (https://t.example/1), not https://example.com/c!"
+	if got := string(rewriteTextLinks([]byte(text), urls)); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,186 @@
+package gmime
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+var ErrMergeCharset = errors.New("Non-ASCII substitution can't be merged into body in charset other than utf-8")
+
+// Recipient is one message of mail merge
+type Recipient struct {
+	// To, CC and other recipient addresses of the message, they replace To and CC
+	// addresses of the template, From and Reply-To are kept. Template ones are used if empty
+	Addresses []*EmailAddress
+	// Substitutions maps tags to values, e.g. "{{first_name}}" to "Ann"
+	Substitutions map[string]string
+}
+
+// RecipientIterator returns recipients of mail merge one by one, Next returns io.EOF after the last one
+type RecipientIterator interface {
+	Next() (*Recipient, error)
+}
+
+type recipientSlice struct {
+	recipients []*Recipient
+}
+
+// RecipientsFromSlice returns RecipientIterator over recipients
+func RecipientsFromSlice(recipients []*Recipient) RecipientIterator {
+	return &recipientSlice{recipients: recipients}
+}
+
+func (s *recipientSlice) Next() (*Recipient, error) {
+	if len(s.recipients) == 0 {
+		return nil, io.EOF
+	}
+	r := s.recipients[0]
+	s.recipients = s.recipients[1:]
+	return r, nil
+}
+
+// MergeExport exports template m once per recipient with substitutions applied to header values,
+// address names, text and html bodies and attachment file names. SetContentPart content is not substituted.
+// Values are HTML-escaped in html body. Values are UTF-8, so a body in other charset
+// takes only ASCII values and returns ErrMergeCharset otherwise.
+// Every message is passed to export, which should Close it, an error of export stops the merge.
+// Attachments are encoded once for all recipients
+func (m *Message) MergeExport(recipients RecipientIterator, export func(r *Recipient, message *MIMEMessage) error) error {
+	embeds, err := preEncodeAttachments(m.embeds)
+	if err != nil {
+		return err
+	}
+	attaches, err := preEncodeAttachments(m.attaches)
+	if err != nil {
+		return err
+	}
+	for {
+		r, err := recipients.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		merged, err := m.merge(r, embeds, attaches)
+		if err != nil {
+			return err
+		}
+		message, err := merged.ExportMIMEMessage()
+		if err != nil {
+			return err
+		}
+		if err := export(r, message); err != nil {
+			return err
+		}
+	}
+}
+
+// merge returns copy of template m for r, embeds and attaches replace ones of m
+func (m *Message) merge(r *Recipient, embeds, attaches []*EmailAttachment) (*Message, error) {
+	replacer := substitutionReplacer(r.Substitutions, nil)
+	merged := *m
+	var err error
+	if merged.text, err = substituteBody(m.text, m.textOptions, r.Substitutions, replacer); err != nil {
+		return nil, err
+	}
+	htmlReplacer := substitutionReplacer(r.Substitutions, html.EscapeString)
+	if merged.html, err = substituteBody(m.html, m.htmlOptions, r.Substitutions, htmlReplacer); err != nil {
+		return nil, err
+	}
+
+	merged.headers = make([]*EmailHeader, 0, len(m.headers))
+	for _, h := range m.headers {
+		c := *h
+		c.Value = replacer.Replace(h.Value)
+		merged.headers = append(merged.headers, &c)
+	}
+
+	merged.addresses = nil
+	for _, a := range m.addresses {
+		if len(r.Addresses) > 0 && (a.AddressType == AddressTo || a.AddressType == AddressCC) {
+			continue
+		}
+		merged.addresses = append(merged.addresses, &EmailAddress{AddressType: a.AddressType, Name: replacer.Replace(a.Name), Address: a.Address, Group: a.Group})
+	}
+	merged.addresses = append(merged.addresses, r.Addresses...)
+
+	merged.embeds = substituteFileNames(embeds, replacer)
+	merged.attaches = substituteFileNames(attaches, replacer)
+	return &merged, nil
+}
+
+// substituteBody returns body with substitutions, a body not in utf-8 takes only ASCII values of its tags
+func substituteBody(body []byte, options *BodyOptions, substitutions map[string]string, replacer *strings.Replacer) ([]byte, error) {
+	if options != nil {
+		switch strings.ToLower(options.Charset) {
+		case "", "utf-8", "utf8":
+		default:
+			for tag, value := range substitutions {
+				if tag != "" && !isASCII(value) && bytes.Contains(body, []byte(tag)) {
+					return nil, ErrMergeCharset
+				}
+			}
+		}
+	}
+	return []byte(replacer.Replace(string(body))), nil
+}
+
+// substitutionReplacer returns replacer of substitutions, longer tags win over their prefixes.
+// Values are passed through escape if it is not nil
+func substitutionReplacer(substitutions map[string]string, escape func(string) string) *strings.Replacer {
+	tags := make([]string, 0, len(substitutions))
+	for tag := range substitutions {
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if len(tags[i]) != len(tags[j]) {
+			return len(tags[i]) > len(tags[j])
+		}
+		return tags[i] < tags[j]
+	})
+	oldnew := make([]string, 0, 2*len(tags))
+	for _, tag := range tags {
+		value := substitutions[tag]
+		if escape != nil {
+			value = escape(value)
+		}
+		oldnew = append(oldnew, tag, value)
+	}
+	return strings.NewReplacer(oldnew...)
+}
+
+func substituteFileNames(attaches []*EmailAttachment, replacer *strings.Replacer) []*EmailAttachment {
+	result := make([]*EmailAttachment, 0, len(attaches))
+	for _, a := range attaches {
+		fileName := replacer.Replace(a.FileName)
+		if fileName != a.FileName {
+			substituted := *a
+			substituted.FileName = fileName
+			a = &substituted
+		}
+		result = append(result, a)
+	}
+	return result
+}
+
+// preEncodeAttachments returns attaches with Content already in OutputEncoding
+func preEncodeAttachments(attaches []*EmailAttachment) ([]*EmailAttachment, error) {
+	result := make([]*EmailAttachment, 0, len(attaches))
+	for _, a := range attaches {
+		// PrepareAttachment returns ErrAttachmentReader for attachments with Reader
+		prepared, err := PrepareAttachment(a)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, prepared.attachment)
+	}
+	return result, nil
+}
@@ -0,0 +1,116 @@
+package gmime
+
+import (
+	"strings"
+	"testing"
+)
+
+func newTestMergeTemplate() *Message {
+	m := NewMessage()
+	m.AddAddress(&EmailAddress{AddressType: AddressFrom, Name: "Shop", Address: "shop@example.com"})
+	m.AddAddress(&EmailAddress{AddressType: AddressTo, Address: "template@example.com"})
+	m.SetHeader(&EmailHeader{Name: "Subject", Value: "Hi {{name}}"})
+	m.SetHeader(&EmailHeader{Name: "X-Campaign", Value: "spring-{{id}}"})
+	m.SetText([]byte("Hello {{name}}"))
+	m.SetHtml([]byte("<p>Hello {{name}}</p>"))
+	m.Attach(&EmailAttachment{FileName: "{{id}}.txt", MimeType: "text/plain", Content: []byte("same for everyone")})
+	return m
+}
+
+func TestMergeExport(t *testing.T) {
+	recipients := []*Recipient{
+		{
+			Addresses:     []*EmailAddress{{AddressType: AddressTo, Address: "ann@example.org"}},
+			Substitutions: map[string]string{"{{name}}": "Ann & Co", "{{id}}": "1"},
+		},
+		{
+			Addresses:     []*EmailAddress{{AddressType: AddressTo, Address: "bob@example.org"}},
+			Substitutions: map[string]string{"{{name}}": "Bob", "{{id}}": "2"},
+		},
+	}
+	var parsed []*ParsedMessage
+	err := newTestMergeTemplate().MergeExport(RecipientsFromSlice(recipients), func(r *Recipient, message *MIMEMessage) error {
+		defer message.Close()
+		p, err := ParseBytes(message.Body)
+		if err != nil {
+			return err
+		}
+		parsed = append(parsed, p)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("MergeExport: %v", err)
+	}
+	if len(parsed) != len(recipients) {
+		t.Fatalf("got %d messages, want %d", len(parsed), len(recipients))
+	}
+
+	for i, p := range parsed {
+		name, id := recipients[i].Substitutions["{{name}}"], recipients[i].Substitutions["{{id}}"]
+		var to []string
+		for _, a := range p.Addresses {
+			if a.AddressType == AddressTo {
+				to = append(to, a.Address)
+			}
+		}
+		if len(to) != 1 || to[0] != recipients[i].Addresses[0].Address {
+			t.Errorf("message %d: To %q, want %s", i, to, recipients[i].Addresses[0].Address)
+		}
+		if got := p.Header("Subject"); got != "Hi "+name {
+			t.Errorf("message %d: Subject %q", i, got)
+		}
+		if got := p.Header("X-Campaign"); got != "spring-"+id {
+			t.Errorf("message %d: X-Campaign %q", i, got)
+		}
+		if got := strings.TrimRight(string(p.Text), "\r\n"); got != "Hello "+name {
+			t.Errorf("message %d: Text %q", i, got)
+		}
+		if want := "<p>Hello " + strings.Replace(name, "&", "&amp;", -1) + "</p>"; !strings.HasPrefix(string(p.Html), want) {
+			t.Errorf("message %d: Html %q, want %q", i, p.Html, want)
+		}
+		if len(p.Attachments) != 1 || p.Attachments[0].FileName != id+".txt" || string(p.Attachments[0].Content) != "same for everyone" {
+			t.Errorf("message %d: attachments %+v", i, p.Attachments)
+		}
+	}
+}
+
+func TestMergeEncodesAttachmentsOnce(t *testing.T) {
+	m := newTestMergeTemplate()
+	attaches, err := preEncodeAttachments(m.attaches)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var contents [][]byte
+	for _, id := range []string{"1", "2"} {
+		merged, err := m.merge(&Recipient{Substitutions: map[string]string{"{{id}}": id}}, nil, attaches)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := merged.attaches[0].FileName; got != id+".txt" {
+			t.Errorf("file name %q, want %s.txt", got, id)
+		}
+		contents = append(contents, merged.attaches[0].Content)
+	}
+	// both messages share the content encoded by preEncodeAttachments
+	if &contents[0][0] != &attaches[0].Content[0] || &contents[1][0] != &attaches[0].Content[0] {
+		t.Error("attachment content was copied for a recipient")
+	}
+	if m.attaches[0].FileName != "{{id}}.txt" {
+		t.Errorf("template file name changed to %q", m.attaches[0].FileName)
+	}
+}
+
+func TestMergeCharset(t *testing.T) {
+	m := newTestMergeTemplate()
+	m.SetTextWithOptions([]byte("Gr\xfc\xdfe {{name}}"), &BodyOptions{Charset: "iso-8859-1"})
+	if _, err := m.merge(&Recipient{Substitutions: map[string]string{"{{name}}": "Zoë"}}, nil, nil); err != ErrMergeCharset {
+		t.Errorf("non-ASCII value: got %v, want ErrMergeCharset", err)
+	}
+	merged, err := m.merge(&Recipient{Substitutions: map[string]string{"{{name}}": "Bob"}}, nil, nil)
+	if err != nil {
+		t.Fatalf("ASCII value: %v", err)
+	}
+	if string(merged.text) != "Gr\xfc\xdfe Bob" {
+		t.Errorf("text %q", merged.text)
+	}
+}
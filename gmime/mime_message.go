@@ -6,7 +6,10 @@ package gmime
 #include <gmime/gmime.h>
 */
 import "C"
-import "unsafe"
+import (
+	"strings"
+	"unsafe"
+)
 
 type EncodedHeader struct {
 	Name  string
@@ -21,3 +24,16 @@ type MIMEMessage struct {
 func (m *MIMEMessage) Close() {
 	C.g_free(unsafe.Pointer(&m.Body[0]))
 }
+
+// dkimEncodedHeaders converts DKIM-Signature fields to EncodedHeader in the form encodedHeadersFromGmime returns
+func dkimEncodedHeaders(fields []string) []*EncodedHeader {
+	var headers []*EncodedHeader
+	for _, field := range fields {
+		headerNameValue := strings.SplitN(strings.Replace(field, "\r\n", "\n", -1), ": ", 2)
+		headers = append(headers, &EncodedHeader{
+			Name:  headerNameValue[0],
+			Value: headerNameValue[1],
+		})
+	}
+	return headers
+}
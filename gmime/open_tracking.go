@@ -0,0 +1,59 @@
+package gmime
+
+import (
+	"bytes"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// OpenTracker returns URL of open tracking image for recipient,
+// recipient is the first To address of exported message, nil if there is none
+type OpenTracker func(recipient *EmailAddress) string
+
+// SetOpenTracker makes exports inject 1x1 image with URL from t into html body,
+// before </body> or at the end if there is no body tag. nil turns it off
+func (m *Message) SetOpenTracker(t OpenTracker) {
+	m.openTracker = t
+}
+
+// injectOpenPixel returns html with tracking image of url
+func injectOpenPixel(body []byte, url string) []byte {
+	pixel := `<img src="` + html.EscapeString(url) + `" width="1" height="1" border="0" alt="" style="display:block;width:1px;height:1px;border:0;margin:0;padding:0" />`
+
+	// last </body> outside of comments and scripts
+	bodyEnd := -1
+	offset := 0
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		if tt == html.EndTagToken {
+			if name, _ := z.TagName(); atom.Lookup(name) == atom.Body {
+				bodyEnd = offset
+			}
+		}
+		offset += len(z.Raw())
+	}
+
+	result := make([]byte, 0, len(body)+len(pixel))
+	if bodyEnd < 0 {
+		result = append(result, body...)
+		return append(result, pixel...)
+	}
+	result = append(result, body[:bodyEnd]...)
+	result = append(result, pixel...)
+	return append(result, body[bodyEnd:]...)
+}
+
+// firstAddress returns the first address of addressType, nil if there is none
+func (m *Message) firstAddress(addressType AddressType) *EmailAddress {
+	for _, a := range m.addresses {
+		if a.AddressType == addressType {
+			return a
+		}
+	}
+	return nil
+}
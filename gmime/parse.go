@@ -0,0 +1,337 @@
+package gmime
+
+/*
+#cgo pkg-config: gmime-2.6
+#include <stdlib.h>
+#include <gmime/gmime.h>
+
+// type checks are macros, wrap them for Go
+static gboolean object_is_multipart(GMimeObject *obj) {
+	return GMIME_IS_MULTIPART(obj);
+}
+
+static gboolean object_is_message_part(GMimeObject *obj) {
+	return GMIME_IS_MESSAGE_PART(obj);
+}
+
+static gboolean object_is_part(GMimeObject *obj) {
+	return GMIME_IS_PART(obj);
+}
+
+static gboolean address_is_group(InternetAddress *ia) {
+	return INTERNET_ADDRESS_IS_GROUP(ia);
+}
+*/
+import "C"
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"unsafe"
+)
+
+var ErrParse = errors.New("Error parsing message")
+
+// ParsedMessage is a navigable representation of a message read by ParseMessage or ParseBytes.
+// Text and Html hold the first inline text/plain and text/html bodies converted to UTF-8,
+// every other leaf part ends up in Embeds or Attachments.
+type ParsedMessage struct {
+	Headers     []*EmailHeader
+	Addresses   []*EmailAddress
+	Text        []byte
+	Html        []byte
+	Embeds      []*EmailAttachment
+	Attachments []*EmailAttachment
+	Root        *ParsedPart
+
+	// Raw is the original message, it is set for the top level message only
+	Raw []byte
+}
+
+// ParsedPart is a node of the parsed MIME tree
+type ParsedPart struct {
+	MediaType   string // lower case type/subtype, e.g. "text/plain"
+	Params      map[string]string
+	Headers     []*EmailHeader
+	Disposition string
+	FileName    string
+	ContentID   string
+	Content     []byte         // decoded content of leaf parts
+	Children    []*ParsedPart  // parts of a multipart
+	Message     *ParsedMessage // message of a message/rfc822 part
+
+	// Raw is the part as it was written, it is set for the signed content of multipart/signed
+	Raw []byte
+}
+
+// ParseMessage reads the whole RFC 5322 message from r and parses it
+func ParseMessage(r io.Reader) (*ParsedMessage, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return ParseBytes(data)
+}
+
+// ParseBytes parses RFC 5322 message
+func ParseBytes(data []byte) (*ParsedMessage, error) {
+	if len(data) == 0 {
+		return nil, ErrParse
+	}
+	stream := C.g_mime_stream_mem_new_with_buffer((*C.char)(unsafe.Pointer(&data[0])), C.size_t(len(data))) // needs unref
+	defer C.g_object_unref(stream)                                                                          // unref
+
+	parser := C.g_mime_parser_new_with_stream(stream) // needs unref
+	defer C.g_object_unref(parser)                    // unref
+
+	message := C.g_mime_parser_construct_message(parser) // needs unref
+	if message == nil {
+		return nil, ErrParse
+	}
+	defer C.g_object_unref(message) // unref
+
+	m := parsedMessageFromGmime(message)
+	m.Raw = data
+	return m, nil
+}
+
+// parsePart parses MIME entity, e.g. decrypted content
+func parsePart(data []byte) (*ParsedPart, error) {
+	obj := objectFromBytes(data) // needs unref
+	if obj == nil {
+		return nil, ErrParse
+	}
+	defer C.g_object_unref(obj) // unref
+	return (&ParsedMessage{}).walk(obj), nil
+}
+
+// Header returns value of the first header with given name, names are case-insensitive
+func (m *ParsedMessage) Header(name string) string {
+	for _, h := range m.Headers {
+		if strings.EqualFold(h.Name, name) {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+func parsedMessageFromGmime(message *C.GMimeMessage) *ParsedMessage {
+	m := &ParsedMessage{
+		Headers:   decodedHeadersFromGmime(anyToGMimeObject(unsafe.Pointer(message))),
+		Addresses: addressesFromGmime(message),
+	}
+	if mimePart := C.g_mime_message_get_mime_part(message); mimePart != nil {
+		m.Root = m.walk(mimePart)
+	}
+	return m
+}
+
+// walk converts GMime object into ParsedPart and sorts leaf parts into bodies, embeds and attachments
+func (m *ParsedMessage) walk(obj *C.GMimeObject) *ParsedPart {
+	p := &ParsedPart{
+		Params:      map[string]string{},
+		Headers:     decodedHeadersFromGmime(obj),
+		Disposition: strings.ToLower(C.GoString(C.g_mime_object_get_disposition(obj))),
+		ContentID:   C.GoString(C.g_mime_object_get_content_id(obj)),
+	}
+
+	contentType := C.g_mime_object_get_content_type(obj)
+	if contentType != nil {
+		p.MediaType = strings.ToLower(C.GoString(C.g_mime_content_type_get_media_type(contentType)) + "/" +
+			C.GoString(C.g_mime_content_type_get_media_subtype(contentType)))
+		for param := C.g_mime_content_type_get_params(contentType); param != nil; param = C.g_mime_param_next(param) {
+			p.Params[strings.ToLower(C.GoString(C.g_mime_param_get_name(param)))] = C.GoString(C.g_mime_param_get_value(param))
+		}
+	}
+
+	switch {
+	case gobool(C.object_is_multipart(obj)):
+		multipart := (*C.GMimeMultipart)(unsafe.Pointer(obj))
+		count := int(C.g_mime_multipart_get_count(multipart))
+		for i := 0; i < count; i++ {
+			child := C.g_mime_multipart_get_part(multipart, C.int(i))
+			p.Children = append(p.Children, m.walk(child))
+			if i == 0 && p.MediaType == "multipart/signed" {
+				p.Children[0].Raw = cryptoContentBytes(child, false)
+			}
+		}
+	case gobool(C.object_is_message_part(obj)):
+		if message := C.g_mime_message_part_get_message((*C.GMimeMessagePart)(unsafe.Pointer(obj))); message != nil {
+			p.Message = parsedMessageFromGmime(message)
+		}
+	case gobool(C.object_is_part(obj)):
+		part := (*C.GMimePart)(unsafe.Pointer(obj))
+		p.FileName = C.GoString(C.g_mime_part_get_filename(part))
+		p.Content = partContent(part, p.textCharset())
+		m.classify(p)
+	}
+	return p
+}
+
+func (m *ParsedMessage) classify(p *ParsedPart) {
+	if p.Disposition != C.GMIME_DISPOSITION_ATTACHMENT && p.FileName == "" {
+		switch {
+		case p.MediaType == "text/plain" && m.Text == nil:
+			m.Text = p.Content
+			return
+		case p.MediaType == "text/html" && m.Html == nil:
+			m.Html = p.Content
+			return
+		}
+	}
+
+	attachment := &EmailAttachment{
+		FileName:    p.FileName,
+		MimeType:    p.MediaType,
+		ContentID:   p.ContentID,
+		Disposition: p.Disposition,
+		Content:     p.Content,
+	}
+	if p.Disposition == C.GMIME_DISPOSITION_INLINE || (p.Disposition == "" && p.ContentID != "") {
+		m.Embeds = append(m.Embeds, attachment)
+	} else {
+		m.Attachments = append(m.Attachments, attachment)
+	}
+}
+
+// textCharset returns charset which content of a text part is converted from, "" means no conversion
+func (p *ParsedPart) textCharset() string {
+	if !strings.HasPrefix(p.MediaType, "text/") {
+		return ""
+	}
+	switch charset := strings.ToLower(p.Params["charset"]); charset {
+	case "", "utf-8", "utf8", "us-ascii":
+		return ""
+	default:
+		return charset
+	}
+}
+
+// partContent returns content of a part with transfer encoding removed,
+// converted from charset to UTF-8 if charset is not empty
+func partContent(part *C.GMimePart, charset string) []byte {
+	wrapper := C.g_mime_part_get_content_object(part)
+	if wrapper == nil {
+		return nil
+	}
+
+	rawStream := C.g_mime_stream_mem_new() // needs unref
+	defer C.g_object_unref(rawStream)      // unref
+
+	stream := C.g_mime_stream_filter_new(rawStream) // needs unref
+	defer C.g_object_unref(stream)                  // unref
+
+	if charset != "" {
+		cCharset := C.CString(charset)                                             // needs free
+		filterCharset := C.g_mime_filter_charset_new(cCharset, cStringCharsetUTF8) // needs unref, NULL if conversion is not supported
+		C.free(unsafe.Pointer(cCharset))                                           // free
+		if filterCharset != nil {
+			C.g_mime_stream_filter_add((*C.GMimeStreamFilter)(unsafe.Pointer(stream)), filterCharset)
+			C.g_object_unref(filterCharset) // unref
+		}
+	}
+
+	C.g_mime_data_wrapper_write_to_stream(wrapper, stream)
+	C.g_mime_stream_flush(stream)
+
+	// byteArray is owned by rawStream and will be freed with it
+	byteArray := C.g_mime_stream_mem_get_byte_array((*C.GMimeStreamMem)(unsafe.Pointer(rawStream)))
+	return C.GoBytes(unsafe.Pointer(byteArray.data), (C.int)(byteArray.len))
+}
+
+// decodedHeadersFromGmime returns headers of an object with RFC 2047 words decoded and folding removed
+func decodedHeadersFromGmime(obj *C.GMimeObject) []*EmailHeader {
+	var iter C.GMimeHeaderIter
+	var headers []*EmailHeader
+	headerList := C.g_mime_object_get_header_list(obj)
+	if C.g_mime_header_list_get_iter(headerList, &iter) == C.TRUE {
+		for {
+			if val := C.g_mime_header_iter_get_value(&iter); val != nil {
+				decoded := C.g_mime_utils_header_decode_text(val) // needs g_free
+				headers = append(headers, &EmailHeader{
+					Name:  C.GoString(C.g_mime_header_iter_get_name(&iter)),
+					Value: unfoldHeaderValue(C.GoString(decoded)),
+				})
+				C.g_free(C.gpointer(decoded))
+			}
+			if C.g_mime_header_iter_next(&iter) == C.FALSE {
+				break
+			}
+		}
+	}
+	return headers
+}
+
+func unfoldHeaderValue(value string) string {
+	value = strings.Replace(value, "\r\n", "\n", -1)
+	value = strings.Replace(value, "\n\t", " ", -1)
+	value = strings.Replace(value, "\n ", " ", -1)
+	return strings.TrimSpace(value)
+}
+
+func addressesFromGmime(message *C.GMimeMessage) []*EmailAddress {
+	var addresses []*EmailAddress
+	addresses = append(addresses, addressesFromHeader(C.g_mime_message_get_sender(message), AddressFrom)...)
+	addresses = append(addresses, addressesFromHeader(C.g_mime_message_get_reply_to(message), AddressReplyTo)...)
+	// list is owned by message
+	addresses = append(addresses, addressesFromList(C.g_mime_message_get_recipients(message, C.GMIME_RECIPIENT_TYPE_TO), AddressTo)...)
+	addresses = append(addresses, addressesFromList(C.g_mime_message_get_recipients(message, C.GMIME_RECIPIENT_TYPE_CC), AddressCC)...)
+	addresses = append(addresses, addressesFromList(C.g_mime_message_get_recipients(message, C.GMIME_RECIPIENT_TYPE_BCC), AddressBCC)...)
+	obj := anyToGMimeObject(unsafe.Pointer(message))
+	for _, h := range addressHeaders {
+		name := C.CString(h.name) // needs free
+		// value is owned by message
+		addresses = append(addresses, addressesFromHeader(C.g_mime_object_get_header(obj, name), h.addressType)...)
+		C.free(unsafe.Pointer(name)) // free
+	}
+	resentBCC := C.CString("Resent-Bcc") // needs free
+	addresses = append(addresses, addressesFromHeader(C.g_mime_object_get_header(obj, resentBCC), AddressResentBCC)...)
+	C.free(unsafe.Pointer(resentBCC)) // free
+	return addresses
+}
+
+func addressesFromHeader(value *C.char, addressType AddressType) []*EmailAddress {
+	if value == nil {
+		return nil
+	}
+	list := C.internet_address_list_parse_string(value) // needs unref
+	if list == nil {
+		return nil
+	}
+	defer C.g_object_unref(list) // unref
+	return addressesFromList(list, addressType)
+}
+
+// addressesFromList flattens InternetAddressList, members of groups are returned as separate addresses
+// with Group set, a group without members is returned as one address with empty Address
+func addressesFromList(list *C.InternetAddressList, addressType AddressType) []*EmailAddress {
+	return addressesFromGroup(list, addressType, "")
+}
+
+func addressesFromGroup(list *C.InternetAddressList, addressType AddressType, group string) []*EmailAddress {
+	var addresses []*EmailAddress
+	if list == nil {
+		return nil
+	}
+	count := int(C.internet_address_list_length(list))
+	for i := 0; i < count; i++ {
+		ia := C.internet_address_list_get_address(list, C.int(i))
+		if gobool(C.address_is_group(ia)) {
+			name := C.GoString(C.internet_address_get_name(ia))
+			members := addressesFromGroup(C.internet_address_group_get_members((*C.InternetAddressGroup)(unsafe.Pointer(ia))), addressType, name)
+			if len(members) == 0 {
+				members = []*EmailAddress{{AddressType: addressType, Group: name}}
+			}
+			addresses = append(addresses, members...)
+			continue
+		}
+		addresses = append(addresses, &EmailAddress{
+			AddressType: addressType,
+			Name:        C.GoString(C.internet_address_get_name(ia)),
+			Address:     C.GoString(C.internet_address_mailbox_get_addr((*C.InternetAddressMailbox)(unsafe.Pointer(ia)))),
+			Group:       group,
+		})
+	}
+	return addresses
+}
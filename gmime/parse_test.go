@@ -0,0 +1,135 @@
+package gmime
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const testParseMessage = "From: Sender <sender@example.com>\n" +
+	"To: rcpt@example.org, Group: a@example.org, b@example.org;\n" +
+	"Subject: =?utf-8?q?Caf=C3=A9?=\n" +
+	"MIME-Version: 1.0\n" +
+	"Content-Type: multipart/mixed; boundary=\"mixed\"\n" +
+	"\n" +
+	"--mixed\n" +
+	"Content-Type: multipart/related; boundary=\"related\"\n" +
+	"\n" +
+	"--related\n" +
+	"Content-Type: multipart/alternative; boundary=\"alternative\"\n" +
+	"\n" +
+	"--alternative\n" +
+	"Content-Type: text/plain; charset=utf-8\n" +
+	"Content-Transfer-Encoding: quoted-printable\n" +
+	"\n" +
+	"Caf=C3=A9 text\n" +
+	"--alternative\n" +
+	"Content-Type: text/html; charset=utf-8\n" +
+	"\n" +
+	"<p>Caf\xc3\xa9 <img src=\"cid:logo@example.com\"></p>\n" +
+	"--alternative--\n" +
+	"--related\n" +
+	"Content-Type: image/png\n" +
+	"Content-Transfer-Encoding: base64\n" +
+	"Content-Disposition: inline\n" +
+	"Content-Id: <logo@example.com>\n" +
+	"\n" +
+	"iVBORw0KGgo=\n" +
+	"--related--\n" +
+	"--mixed\n" +
+	"Content-Type: text/plain; charset=iso-8859-1\n" +
+	"Content-Transfer-Encoding: 8bit\n" +
+	"Content-Disposition: attachment; filename=\"notes.txt\"\n" +
+	"\n" +
+	"Gr\xfc\xdfe\n" +
+	"--mixed--\n"
+
+func TestParseBytes(t *testing.T) {
+	m, err := ParseBytes([]byte(testParseMessage))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+
+	if got := m.Header("subject"); got != "Café" {
+		t.Errorf("Subject %q, want %q", got, "Café")
+	}
+	wantAddresses := []EmailAddress{
+		{AddressType: AddressFrom, Name: "Sender", Address: "sender@example.com"},
+		{AddressType: AddressTo, Address: "rcpt@example.org"},
+		{AddressType: AddressTo, Address: "a@example.org", Group: "Group"},
+		{AddressType: AddressTo, Address: "b@example.org", Group: "Group"},
+	}
+	if len(m.Addresses) != len(wantAddresses) {
+		t.Fatalf("got %d addresses, want %d", len(m.Addresses), len(wantAddresses))
+	}
+	for i, want := range wantAddresses {
+		if *m.Addresses[i] != want {
+			t.Errorf("address %d is %+v, want %+v", i, *m.Addresses[i], want)
+		}
+	}
+
+	// newline before a boundary belongs to the boundary
+	if strings.TrimRight(string(m.Text), "\n") != "Café text" {
+		t.Errorf("Text %q", m.Text)
+	}
+	if !bytes.HasPrefix(m.Html, []byte("<p>Café")) {
+		t.Errorf("Html %q", m.Html)
+	}
+
+	if len(m.Embeds) != 1 {
+		t.Fatalf("got %d embeds, want 1", len(m.Embeds))
+	}
+	if e := m.Embeds[0]; e.MimeType != "image/png" || e.ContentID != "logo@example.com" || !bytes.Equal(e.Content, []byte("\x89PNG\r\n\x1a\n")) {
+		t.Errorf("embed %s %s %q", e.MimeType, e.ContentID, e.Content)
+	}
+
+	// non-UTF-8 text is converted
+	if len(m.Attachments) != 1 {
+		t.Fatalf("got %d attachments, want 1", len(m.Attachments))
+	}
+	if a := m.Attachments[0]; a.FileName != "notes.txt" || a.MimeType != "text/plain" || strings.TrimRight(string(a.Content), "\n") != "Grüße" {
+		t.Errorf("attachment %s %s %q", a.FileName, a.MimeType, a.Content)
+	}
+
+	root := m.Root
+	if root.MediaType != "multipart/mixed" || len(root.Children) != 2 {
+		t.Fatalf("root %s with %d children", root.MediaType, len(root.Children))
+	}
+	related := root.Children[0]
+	if related.MediaType != "multipart/related" || len(related.Children) != 2 || related.Children[0].MediaType != "multipart/alternative" {
+		t.Errorf("related part %s with %d children", related.MediaType, len(related.Children))
+	}
+	if got := root.Children[1].Params["charset"]; got != "iso-8859-1" {
+		t.Errorf("charset param %q", got)
+	}
+}
+
+func TestParseMessageEmpty(t *testing.T) {
+	if _, err := ParseBytes(nil); err != ErrParse {
+		t.Errorf("got %v, want ErrParse", err)
+	}
+}
+
+// exportParsed exports m and parses the result back
+func exportParsed(t *testing.T, m *Message) ([]byte, *ParsedMessage) {
+	data, err := m.Export()
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	parsed, err := ParseBytes(data)
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+	return data, parsed
+}
+
+// headerValues returns values of parsed headers named name in their order
+func headerValues(m *ParsedMessage, name string) []string {
+	var values []string
+	for _, h := range m.Headers {
+		if strings.EqualFold(h.Name, name) {
+			values = append(values, h.Value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,225 @@
+package gmime
+
+/*
+#cgo pkg-config: gmime-2.6
+#include <stdlib.h>
+#include <gmime/gmime.h>
+
+ssize_t raw_header_writer(GMimeStream *stream, const char *name, const char *value);
+*/
+import "C"
+import (
+	"errors"
+	"strings"
+	"unsafe"
+)
+
+var (
+	ErrMediaType      = errors.New("Media type should be in type/subtype form")
+	ErrLeafChildren   = errors.New("Only multipart can have child parts")
+	ErrEmptyMultipart = errors.New("Multipart has no child parts")
+)
+
+// Part is a node of a MIME tree, it is used with Message.SetContentPart
+// to compose structures which Message does not build by itself
+type Part struct {
+	mediaType   string
+	params      [][2]string
+	headers     []*EmailHeader
+	disposition string
+	fileName    string
+	content     []byte
+	encoding    *EncodingType
+	children    []*Part
+	message     *Message
+}
+
+// NewMultipart returns multipart/<subtype> part
+func NewMultipart(subtype string) *Part {
+	return &Part{mediaType: "multipart/" + subtype}
+}
+
+// NewLeafPart returns part with content, mediaType is in type/subtype form, e.g. "text/x-amp-html"
+func NewLeafPart(mediaType string) *Part {
+	return &Part{mediaType: mediaType}
+}
+
+// NewMessagePart returns message/rfc822 part wrapping m
+func NewMessagePart(m *Message) *Part {
+	return &Part{mediaType: "message/rfc822", message: m}
+}
+
+func (p *Part) AddChild(child *Part) {
+	p.children = append(p.children, child)
+}
+
+// SetParam sets Content-Type parameter
+func (p *Part) SetParam(name, value string) {
+	for i := range p.params {
+		if strings.EqualFold(p.params[i][0], name) {
+			p.params[i][1] = value
+			return
+		}
+	}
+	p.params = append(p.params, [2]string{name, value})
+}
+
+func (p *Part) SetContent(content []byte) {
+	p.content = content
+}
+
+// SetEncoding sets Content-Transfer-Encoding, if not set the best encoding for the content is used
+func (p *Part) SetEncoding(encoding EncodingType) {
+	p.encoding = &encoding
+}
+
+func (p *Part) SetDisposition(disposition string) {
+	p.disposition = disposition
+}
+
+// SetFileName sets filename of a leaf part
+func (p *Part) SetFileName(fileName string) {
+	p.fileName = fileName
+}
+
+func (p *Part) AppendHeader(h *EmailHeader) {
+	p.headers = append(p.headers, h)
+}
+
+// returns GMimeObject and streams of Reader attachments of embedded messages
+// caller responsible for unref
+func (p *Part) gmimize(boundaries BoundaryGenerator) (*C.GMimeObject, []*goStream, error) {
+	mediaType, mediaSubtype, ok := splitMediaType(p.mediaType)
+	if !ok {
+		return nil, nil, ErrMediaType
+	}
+	if err := validatePart(p); err != nil {
+		return nil, nil, err
+	}
+	if len(p.children) > 0 && !strings.EqualFold(mediaType, "multipart") {
+		return nil, nil, ErrLeafChildren
+	}
+
+	var obj *C.GMimeObject
+	var readers []*goStream
+	switch {
+	case strings.EqualFold(mediaType, "multipart"):
+		if len(p.children) == 0 {
+			return nil, nil, ErrEmptyMultipart
+		}
+		subtype := C.CString(mediaSubtype)                        // needs free
+		multipart := newMultiPartWithSubtype(subtype, boundaries) // caller to unref
+		C.free(unsafe.Pointer(subtype))                           // free
+		obj = anyToGMimeObject(unsafe.Pointer(multipart))
+		for _, child := range p.children {
+			childObj, childReaders, err := child.gmimize(boundaries) // needs unref
+			if err != nil {
+				C.g_object_unref(obj)
+				return nil, nil, err
+			}
+			readers = append(readers, childReaders...)
+			C.g_mime_multipart_add(multipart, childObj)
+			C.g_object_unref(childObj) // unref
+		}
+	case p.message != nil:
+		// embedded message uses its own generator if it has one
+		messageBoundaries := p.message.boundaries
+		if messageBoundaries == nil {
+			messageBoundaries = boundaries
+		}
+		message, messageReaders, err := p.message.gmimize(messageBoundaries) // needs unref
+		if err != nil {
+			return nil, nil, err
+		}
+		readers = messageReaders
+		subtype := C.CString(mediaSubtype)                                      // needs free
+		messagePart := C.g_mime_message_part_new_with_message(subtype, message) // caller to unref
+		C.free(unsafe.Pointer(subtype))                                         // free
+		C.g_object_unref(message)                                               // unref
+		obj = anyToGMimeObject(unsafe.Pointer(messagePart))
+	default:
+		obj = leafPart(mediaType, mediaSubtype, p.content, p.encoding) // caller to unref
+	}
+
+	for _, param := range p.params {
+		name := C.CString(param[0])  // needs free
+		value := C.CString(param[1]) // needs free
+		C.g_mime_object_set_content_type_parameter(obj, name, value)
+		C.free(unsafe.Pointer(name))  // free
+		C.free(unsafe.Pointer(value)) // free
+	}
+
+	if p.disposition != "" {
+		disposition := C.CString(p.disposition) // needs free
+		C.g_mime_object_set_disposition(obj, disposition)
+		C.free(unsafe.Pointer(disposition)) // free
+	}
+
+	if p.fileName != "" && p.message == nil && len(p.children) == 0 {
+		fileName := C.CString(p.fileName) // needs free
+		C.g_mime_part_set_filename((*C.GMimePart)(unsafe.Pointer(obj)), fileName)
+		C.free(unsafe.Pointer(fileName)) // free
+	}
+
+	appendPartHeaders(obj, p.headers)
+
+	return obj, readers, nil
+}
+
+// appendPartHeaders appends headers to part obj with the same FoldingPolicy rules as message headers:
+// values are RFC 2047 encoded and folded by GMime unless a header of the name has other policy
+func appendPartHeaders(obj *C.GMimeObject, headers []*EmailHeader) {
+	headerList := C.g_mime_object_get_header_list(obj)
+	rawNames := rawWriterNames(headers)
+	for _, h := range headers {
+		name := C.CString(h.Name) // needs free
+		if rawNames[strings.ToLower(h.Name)] {
+			value := C.CString(rawHeaderValue(h, false)) // needs free
+			C.g_mime_header_list_register_writer(headerList, name, (C.GMimeHeaderWriter)(unsafe.Pointer(C.raw_header_writer)))
+			C.g_mime_object_append_header(obj, name, value)
+			C.free(unsafe.Pointer(value))
+		} else {
+			value := C.CString(h.Value)                              // needs free
+			encodedValue := C.g_mime_utils_header_encode_text(value) // needs g_free
+			C.g_mime_object_append_header(obj, name, encodedValue)
+			C.g_free(C.gpointer(encodedValue))
+			C.free(unsafe.Pointer(value))
+		}
+		C.free(unsafe.Pointer(name))
+	}
+}
+
+// returns GMimePart as GMimeObject
+// caller responsible for unref
+func leafPart(mediaType, mediaSubtype string, content []byte, encoding *EncodingType) *C.GMimeObject {
+	cStringMimeType := C.CString(mediaType)                                  // needs free
+	cStringMimeSubType := C.CString(mediaSubtype)                            // needs free
+	part := C.g_mime_part_new_with_type(cStringMimeType, cStringMimeSubType) // caller to unref
+	C.free(unsafe.Pointer(cStringMimeType))                                  // free
+	C.free(unsafe.Pointer(cStringMimeSubType))                               // free
+
+	dataWrapper := dataWrapperFromBytes(content, EncodingDefault) // needs unref
+	C.g_mime_part_set_content_object(part, dataWrapper)
+	C.g_object_unref(dataWrapper) // unref
+
+	if encoding != nil {
+		C.g_mime_part_set_content_encoding(part, (C.GMimeContentEncoding)(*encoding))
+	} else {
+		C.g_mime_part_set_content_encoding(part, C.g_mime_part_get_best_content_encoding(part, C.GMIME_ENCODING_CONSTRAINT_7BIT))
+	}
+	return anyToGMimeObject(unsafe.Pointer(part))
+}
+
+// dataWrapperFromBytes copies content into a GMime memory stream, content may contain NUL bytes
+// returns GMimeDataWrapper, caller responsible for unref
+func dataWrapperFromBytes(content []byte, encoding EncodingType) *C.GMimeDataWrapper {
+	var mem *C.GMimeStream
+	if len(content) == 0 {
+		mem = C.g_mime_stream_mem_new() // needs unref
+	} else {
+		mem = C.g_mime_stream_mem_new_with_buffer((*C.char)(unsafe.Pointer(&content[0])), C.size_t(len(content))) // needs unref
+	}
+	dataWrapper := C.g_mime_data_wrapper_new_with_stream(mem, (C.GMimeContentEncoding)(encoding)) // caller to unref
+	C.g_object_unref(mem)                                                                         // unref
+	return dataWrapper
+}
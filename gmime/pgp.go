@@ -0,0 +1,152 @@
+package gmime
+
+/*
+#cgo pkg-config: gmime-2.6
+#include <gmime/gmime.h>
+*/
+import "C"
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"io/ioutil"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+var (
+	ErrSMIMEAndPGP     = errors.New("Message can't be both S/MIME and PGP/MIME")
+	ErrNotPGPSigned    = errors.New("Part is not PGP/MIME multipart/signed")
+	ErrNotPGPEncrypted = errors.New("Part is not PGP/MIME multipart/encrypted")
+)
+
+// PGPOptions makes exported message PGP/MIME (RFC 3156) signed, encrypted or both.
+// Keys come from an in-process keyring, private keys should be decrypted already.
+// When both are set the content is signed and encrypted in one OpenPGP message.
+// Types are of github.com/ProtonMail/go-crypto/openpgp, the maintained fork of golang.org/x/crypto/openpgp
+type PGPOptions struct {
+	SignWith  *openpgp.Entity   // sign with this entity if set
+	EncryptTo []*openpgp.Entity // encrypt for these recipients if set
+	Config    *packet.Config    // optional, hash and cipher preferences
+}
+
+// SetPGP makes exports PGP/MIME signed and/or encrypted, nil turns it off
+func (m *Message) SetPGP(options *PGPOptions) {
+	m.pgp = options
+}
+
+// wrap returns content wrapped into multipart/signed or multipart/encrypted
+// caller responsible for unref
+func (o *PGPOptions) wrap(content *C.GMimeObject, boundaries BoundaryGenerator) (*C.GMimeObject, error) {
+	if len(o.EncryptTo) > 0 {
+		var encrypted bytes.Buffer
+		armored, err := armor.Encode(&encrypted, "PGP MESSAGE", nil)
+		if err != nil {
+			return nil, err
+		}
+		plaintext, err := openpgp.Encrypt(armored, o.EncryptTo, o.SignWith, nil, o.Config)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := plaintext.Write(toCRLF(cryptoContentBytes(content, false))); err != nil {
+			return nil, err
+		}
+		if err := plaintext.Close(); err != nil {
+			return nil, err
+		}
+		if err := armored.Close(); err != nil {
+			return nil, err
+		}
+
+		control := NewLeafPart("application/pgp-encrypted")
+		control.SetContent([]byte("Version: 1\n"))
+		control.SetEncoding(Encoding7bit)
+
+		data := NewLeafPart("application/octet-stream")
+		data.SetDisposition(C.GMIME_DISPOSITION_INLINE)
+		data.SetFileName("encrypted.asc")
+		data.SetContent(encrypted.Bytes())
+		data.SetEncoding(Encoding7bit)
+
+		multipart := NewMultipart("encrypted")
+		multipart.SetParam("protocol", "application/pgp-encrypted")
+		multipart.AddChild(control)
+		multipart.AddChild(data)
+		obj, _, err := multipart.gmimize(boundaries) // parts hold bytes, no readers
+		return obj, err
+	}
+
+	if o.SignWith != nil {
+		data := cryptoContentBytes(content, true)
+		var signature bytes.Buffer
+		if err := openpgp.ArmoredDetachSign(&signature, o.SignWith, bytes.NewReader(toCRLF(data)), o.Config); err != nil {
+			return nil, err
+		}
+
+		signaturePart := NewLeafPart("application/pgp-signature")
+		signaturePart.SetParam("name", "signature.asc")
+		signaturePart.SetContent(signature.Bytes())
+		signaturePart.SetEncoding(Encoding7bit)
+		return signedMultipart(data, signaturePart, "application/pgp-signature", pgpMicalg(o.Config.Hash()), boundaries)
+	}
+
+	C.g_object_ref(C.gpointer(content))
+	return content, nil
+}
+
+func pgpMicalg(hash crypto.Hash) string {
+	switch hash {
+	case crypto.SHA1:
+		return "pgp-sha1"
+	case crypto.SHA224:
+		return "pgp-sha224"
+	case crypto.SHA384:
+		return "pgp-sha384"
+	case crypto.SHA512:
+		return "pgp-sha512"
+	default:
+		return "pgp-sha256"
+	}
+}
+
+// VerifyPGP checks PGP/MIME multipart/signed part and returns the entity which signed it
+func (p *ParsedPart) VerifyPGP(keyring openpgp.KeyRing) (*openpgp.Entity, error) {
+	if p.MediaType != "multipart/signed" || !strings.EqualFold(p.Params["protocol"], "application/pgp-signature") || len(p.Children) != 2 {
+		return nil, ErrNotPGPSigned
+	}
+	return openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(normalizeCRLF(p.Children[0].Raw)), bytes.NewReader(p.Children[1].Content), nil)
+}
+
+// DecryptPGP decrypts PGP/MIME multipart/encrypted part and returns the decrypted part,
+// signer is not nil if the content was signed and the signature is valid
+func (p *ParsedPart) DecryptPGP(keyring openpgp.KeyRing) (part *ParsedPart, signer *openpgp.Entity, err error) {
+	if p.MediaType != "multipart/encrypted" || !strings.EqualFold(p.Params["protocol"], "application/pgp-encrypted") || len(p.Children) != 2 {
+		return nil, nil, ErrNotPGPEncrypted
+	}
+	block, err := armor.Decode(bytes.NewReader(p.Children[1].Content))
+	if err != nil {
+		return nil, nil, err
+	}
+	md, err := openpgp.ReadMessage(block.Body, keyring, nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	plaintext, err := ioutil.ReadAll(md.UnverifiedBody)
+	if err != nil {
+		return nil, nil, err
+	}
+	// signature is checked once the body is read
+	if md.IsSigned {
+		if md.SignatureError != nil {
+			return nil, nil, md.SignatureError
+		}
+		if md.SignedBy != nil {
+			signer = md.SignedBy.Entity
+		}
+	}
+	part, err = parsePart(plaintext)
+	return part, signer, err
+}
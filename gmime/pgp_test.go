@@ -0,0 +1,104 @@
+package gmime
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+const testPGPText = "Hello over PGP/MIME"
+
+// exportPGP returns parsed export of a text message with PGP options o
+func exportPGP(t *testing.T, o *PGPOptions) *ParsedMessage {
+	m := NewMessage()
+	m.AddAddress(&EmailAddress{AddressType: AddressFrom, Address: "sender@example.com"})
+	m.AddAddress(&EmailAddress{AddressType: AddressTo, Address: "rcpt@example.org"})
+	m.SetText([]byte(testPGPText))
+	m.SetPGP(o)
+	data, err := m.Export()
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	parsed, err := ParseBytes(data)
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+	return parsed
+}
+
+func newTestEntity(t *testing.T, name, email string) *openpgp.Entity {
+	entity, err := openpgp.NewEntity(name, "", email, nil)
+	if err != nil {
+		t.Fatalf("NewEntity: %v", err)
+	}
+	return entity
+}
+
+func TestPGPSignVerify(t *testing.T) {
+	sender := newTestEntity(t, "Sender", "sender@example.com")
+	parsed := exportPGP(t, &PGPOptions{SignWith: sender})
+
+	signer, err := parsed.Root.VerifyPGP(openpgp.EntityList{sender})
+	if err != nil {
+		t.Fatalf("VerifyPGP: %v", err)
+	}
+	if signer.PrimaryKey.KeyId != sender.PrimaryKey.KeyId {
+		t.Errorf("signed by %X, want %X", signer.PrimaryKey.KeyId, sender.PrimaryKey.KeyId)
+	}
+
+	other := newTestEntity(t, "Other", "other@example.com")
+	if _, err := parsed.Root.VerifyPGP(openpgp.EntityList{other}); err == nil {
+		t.Error("VerifyPGP with unknown key succeeded")
+	}
+}
+
+func TestPGPVerifyTampered(t *testing.T) {
+	sender := newTestEntity(t, "Sender", "sender@example.com")
+	parsed := exportPGP(t, &PGPOptions{SignWith: sender})
+
+	tampered, err := ParseBytes(bytes.Replace(parsed.Raw, []byte(testPGPText), []byte("Hello over SMTP"), 1))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+	if _, err := tampered.Root.VerifyPGP(openpgp.EntityList{sender}); err == nil {
+		t.Error("VerifyPGP of tampered content succeeded")
+	}
+}
+
+func TestPGPEncryptDecrypt(t *testing.T) {
+	sender := newTestEntity(t, "Sender", "sender@example.com")
+	recipient := newTestEntity(t, "Recipient", "rcpt@example.org")
+
+	tests := []struct {
+		name     string
+		signWith *openpgp.Entity
+	}{
+		{"encrypted", nil},
+		{"signed and encrypted", sender},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed := exportPGP(t, &PGPOptions{SignWith: tt.signWith, EncryptTo: []*openpgp.Entity{recipient}})
+
+			part, signer, err := parsed.Root.DecryptPGP(openpgp.EntityList{recipient, sender})
+			if err != nil {
+				t.Fatalf("DecryptPGP: %v", err)
+			}
+			if got := strings.TrimRight(string(part.Content), "\r\n"); got != testPGPText {
+				t.Errorf("decrypted %q, want %q", got, testPGPText)
+			}
+			switch {
+			case tt.signWith == nil && signer != nil:
+				t.Errorf("unsigned content has signer %X", signer.PrimaryKey.KeyId)
+			case tt.signWith != nil && (signer == nil || signer.PrimaryKey.KeyId != tt.signWith.PrimaryKey.KeyId):
+				t.Errorf("signer is %v, want %X", signer, tt.signWith.PrimaryKey.KeyId)
+			}
+
+			if _, _, err := parsed.Root.DecryptPGP(openpgp.EntityList{sender}); err == nil {
+				t.Error("DecryptPGP without recipient key succeeded")
+			}
+		})
+	}
+}
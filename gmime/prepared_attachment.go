@@ -0,0 +1,80 @@
+package gmime
+
+/*
+#cgo pkg-config: gmime-2.6
+#include <gmime/gmime.h>
+*/
+import "C"
+import (
+	"errors"
+	"unsafe"
+)
+
+var ErrAttachmentReader = errors.New("Attachments with Reader can't be prepared or merged, they can be read only once")
+
+// PreparedAttachment is attachment with content encoded once,
+// it is added to any number of messages without encoding it again
+type PreparedAttachment struct {
+	attachment *EmailAttachment
+}
+
+// PrepareAttachment encodes Content of a into its OutputEncoding by GMime encoder,
+// a is not changed
+func PrepareAttachment(a *EmailAttachment) (*PreparedAttachment, error) {
+	if a.Reader != nil {
+		return nil, ErrAttachmentReader
+	}
+	inputEncoding, outputEncoding := EncodingDefault, EncodingBase64
+	if a.InputEncoding != nil {
+		inputEncoding = *a.InputEncoding
+	}
+	if a.OutputEncoding != nil {
+		outputEncoding = *a.OutputEncoding
+	}
+	if inputEncoding == outputEncoding || (outputEncoding != EncodingBase64 && outputEncoding != EncodingQuotedPrintable) {
+		// nothing to encode
+		return &PreparedAttachment{attachment: a}, nil
+	}
+	prepared := *a
+	prepared.Content = encodeContent(a.Content, inputEncoding, outputEncoding)
+	// GMime writes content as is when it is already in the output encoding
+	prepared.InputEncoding = &outputEncoding
+	prepared.OutputEncoding = &outputEncoding
+	return &PreparedAttachment{attachment: &prepared}, nil
+}
+
+// EmbedPrepared is Embed of prepared attachment
+func (m *Message) EmbedPrepared(p *PreparedAttachment) {
+	a := *p.attachment
+	m.Embed(&a)
+}
+
+// AttachPrepared is Attach of prepared attachment
+func (m *Message) AttachPrepared(p *PreparedAttachment) {
+	a := *p.attachment
+	m.Attach(&a)
+}
+
+// encodeContent returns content in inputEncoding encoded into encoding by GMime encoder
+func encodeContent(content []byte, inputEncoding, encoding EncodingType) []byte {
+	wrapper := dataWrapperFromBytes(content, inputEncoding) // needs unref
+	defer C.g_object_unref(wrapper)                         // unref
+
+	rawStream := C.g_mime_stream_mem_new() // needs unref
+	defer C.g_object_unref(rawStream)      // unref
+
+	stream := C.g_mime_stream_filter_new(rawStream) // needs unref
+	defer C.g_object_unref(stream)                  // unref
+
+	filter := C.g_mime_filter_basic_new((C.GMimeContentEncoding)(encoding), C.TRUE) // needs unref
+	C.g_mime_stream_filter_add((*C.GMimeStreamFilter)(unsafe.Pointer(stream)), filter)
+	C.g_object_unref(filter) // unref
+
+	// wrapper writes decoded content, filter encodes it
+	C.g_mime_data_wrapper_write_to_stream(wrapper, stream)
+	C.g_mime_stream_flush(stream)
+
+	// byteArray is owned by rawStream and will be freed with it
+	byteArray := C.g_mime_stream_mem_get_byte_array((*C.GMimeStreamMem)(unsafe.Pointer(rawStream)))
+	return C.GoBytes(unsafe.Pointer(byteArray.data), (C.int)(byteArray.len))
+}
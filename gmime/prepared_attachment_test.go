@@ -0,0 +1,107 @@
+package gmime
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestPreparedAttachmentRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding EncodingType
+		content  []byte
+	}{
+		{"base64", EncodingBase64, []byte("%PDF-1.4\x00\xff\xfe\r\nbinary\x00tail")},
+		{"quoted-printable", EncodingQuotedPrintable, []byte(strings.Repeat("Grüße = 100% ", 20))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoding := tt.encoding
+			embed, err := PrepareAttachment(&EmailAttachment{MimeType: "image/png", ContentID: "logo@example.com", Content: tt.content, OutputEncoding: &encoding})
+			if err != nil {
+				t.Fatal(err)
+			}
+			attachment, err := PrepareAttachment(&EmailAttachment{FileName: "file.bin", MimeType: "application/octet-stream", Content: tt.content, OutputEncoding: &encoding})
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := benchmarkMessage()
+			m.SetHtml([]byte(`<img src="cid:logo@example.com">`))
+			m.EmbedPrepared(embed)
+			m.AttachPrepared(attachment)
+			data, err := m.Export()
+			if err != nil {
+				t.Fatalf("Export: %v", err)
+			}
+			parsed, err := ParseBytes(data)
+			if err != nil {
+				t.Fatalf("ParseBytes: %v", err)
+			}
+			if len(parsed.Embeds) != 1 || !bytes.Equal(parsed.Embeds[0].Content, tt.content) {
+				t.Errorf("embeds %+v, want content %q", parsed.Embeds, tt.content)
+			}
+			if len(parsed.Attachments) != 1 || !bytes.Equal(parsed.Attachments[0].Content, tt.content) {
+				t.Errorf("attachments %+v, want content %q", parsed.Attachments, tt.content)
+			}
+		})
+	}
+}
+
+func TestPrepareAttachmentReader(t *testing.T) {
+	if _, err := PrepareAttachment(&EmailAttachment{Reader: strings.NewReader("data")}); err != ErrAttachmentReader {
+		t.Errorf("got %v, want ErrAttachmentReader", err)
+	}
+}
+
+const benchmarkAttachmentSize = 5 << 20
+
+func benchmarkAttachment() *EmailAttachment {
+	content := make([]byte, benchmarkAttachmentSize)
+	rand.New(rand.NewSource(1)).Read(content)
+	return &EmailAttachment{
+		FileName: "report.pdf",
+		MimeType: "application/pdf",
+		Content:  content,
+	}
+}
+
+func benchmarkMessage() *Message {
+	m := NewMessage()
+	m.AddAddress(&EmailAddress{AddressType: AddressFrom, Address: "sender@example.com"})
+	m.AddAddress(&EmailAddress{AddressType: AddressTo, Address: "rcpt@example.org"})
+	m.SetText([]byte("Report is attached"))
+	return m
+}
+
+// BenchmarkExportAttach encodes the attachment on every export
+func BenchmarkExportAttach(b *testing.B) {
+	a := benchmarkAttachment()
+	b.SetBytes(benchmarkAttachmentSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m := benchmarkMessage()
+		m.Attach(a)
+		if _, err := m.Export(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkExportAttachPrepared encodes the attachment once, exports write it as is
+func BenchmarkExportAttachPrepared(b *testing.B) {
+	prepared, err := PrepareAttachment(benchmarkAttachment())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(benchmarkAttachmentSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m := benchmarkMessage()
+		m.AttachPrepared(prepared)
+		if _, err := m.Export(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package gmime
+
+/*
+#cgo pkg-config: gmime-2.6
+#include <gmime/gmime.h>
+*/
+import "C"
+import (
+	"crypto"
+	"crypto/x509"
+
+	"go.mozilla.org/pkcs7"
+)
+
+// SMIMEOptions makes exported message S/MIME (RFC 5751) signed, encrypted or both.
+// When both are set the message is signed first and the signed entity is encrypted
+type SMIMEOptions struct {
+	SignCertificate *x509.Certificate   // sign with detached PKCS#7 signature if set
+	SignKey         crypto.PrivateKey   // private key of SignCertificate
+	Intermediates   []*x509.Certificate // chain included into the signature
+
+	EncryptTo []*x509.Certificate // produce AES-256-CBC enveloped-data for these recipients, RSA keys only
+}
+
+// SetSMIME makes exports S/MIME signed and/or encrypted, nil turns it off
+func (m *Message) SetSMIME(options *SMIMEOptions) {
+	m.smime = options
+}
+
+// wrap returns content wrapped into multipart/signed and/or application/pkcs7-mime
+// caller responsible for unref
+func (o *SMIMEOptions) wrap(content *C.GMimeObject, boundaries BoundaryGenerator) (*C.GMimeObject, error) {
+	C.g_object_ref(C.gpointer(content)) // result always needs unref, content stays owned by caller
+	if o.SignCertificate != nil {
+		data := cryptoContentBytes(content, true)
+		signedData, err := pkcs7.NewSignedData(toCRLF(data))
+		if err != nil {
+			C.g_object_unref(content)
+			return nil, err
+		}
+		signedData.SetDigestAlgorithm(pkcs7.OIDDigestAlgorithmSHA256)
+		if err := signedData.AddSignerChain(o.SignCertificate, o.SignKey, o.Intermediates, pkcs7.SignerInfoConfig{}); err != nil {
+			C.g_object_unref(content)
+			return nil, err
+		}
+		signedData.Detach()
+		signatureData, err := signedData.Finish()
+		if err != nil {
+			C.g_object_unref(content)
+			return nil, err
+		}
+
+		signature := smimePart("application/pkcs7-signature", "smime.p7s", signatureData)
+		signed, err := signedMultipart(data, signature, "application/pkcs7-signature", "sha-256", boundaries)
+		C.g_object_unref(content)
+		if err != nil {
+			return nil, err
+		}
+		content = signed
+	}
+
+	if len(o.EncryptTo) > 0 {
+		envelopedData, err := envelopedDataAES(toCRLF(cryptoContentBytes(content, false)), o.EncryptTo)
+		C.g_object_unref(content)
+		if err != nil {
+			return nil, err
+		}
+		enveloped := smimePart("application/pkcs7-mime", "smime.p7m", envelopedData)
+		enveloped.SetParam("smime-type", "enveloped-data")
+		obj, _, err := enveloped.gmimize(boundaries) // parts hold bytes, no readers
+		return obj, err
+	}
+	return content, nil
+}
+
+func smimePart(mediaType, fileName string, data []byte) *Part {
+	p := NewLeafPart(mediaType)
+	p.SetDisposition(C.GMIME_DISPOSITION_ATTACHMENT)
+	p.SetFileName(fileName)
+	p.SetContent(data)
+	p.SetEncoding(EncodingBase64)
+	return p
+}
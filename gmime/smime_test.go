@@ -0,0 +1,138 @@
+package gmime
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mozilla.org/pkcs7"
+)
+
+const testSMIMEText = "Hello over S/MIME"
+
+// newTestCertificate returns self-signed certificate with RSA key
+func newTestCertificate(t *testing.T, serial int64, email string) (*x509.Certificate, *rsa.PrivateKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:   big.NewInt(serial),
+		Subject:        pkix.Name{CommonName: email},
+		EmailAddresses: []string{email},
+		NotBefore:      time.Now().Add(-time.Hour),
+		NotAfter:       time.Now().Add(time.Hour),
+		KeyUsage:       x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certificate, key
+}
+
+// exportSMIME returns parsed export of a text message with S/MIME options o
+func exportSMIME(t *testing.T, o *SMIMEOptions) *ParsedMessage {
+	m := NewMessage()
+	m.AddAddress(&EmailAddress{AddressType: AddressFrom, Address: "sender@example.com"})
+	m.AddAddress(&EmailAddress{AddressType: AddressTo, Address: "rcpt@example.org"})
+	m.SetText([]byte(testSMIMEText))
+	m.SetSMIME(o)
+	data, err := m.Export()
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	parsed, err := ParseBytes(data)
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+	return parsed
+}
+
+// verifySMIME checks multipart/signed part p and returns its signed content
+func verifySMIME(t *testing.T, p *ParsedPart, signer *x509.Certificate) *ParsedPart {
+	if p.MediaType != "multipart/signed" || len(p.Children) != 2 || p.Children[1].MediaType != "application/pkcs7-signature" {
+		t.Fatalf("got %s with %d children, want multipart/signed", p.MediaType, len(p.Children))
+	}
+	p7, err := pkcs7.Parse(p.Children[1].Content)
+	if err != nil {
+		t.Fatalf("pkcs7.Parse: %v", err)
+	}
+	p7.Content = normalizeCRLF(p.Children[0].Raw)
+	if err := p7.Verify(); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if got := p7.GetOnlySigner(); got == nil || !got.Equal(signer) {
+		t.Errorf("signed by %v, want %v", got, signer.Subject)
+	}
+	return p.Children[0]
+}
+
+// decryptSMIME decrypts application/pkcs7-mime part p
+func decryptSMIME(t *testing.T, p *ParsedPart, certificate *x509.Certificate, key *rsa.PrivateKey) *ParsedPart {
+	if p.MediaType != "application/pkcs7-mime" || p.Params["smime-type"] != "enveloped-data" {
+		t.Fatalf("got %s %v, want enveloped-data", p.MediaType, p.Params)
+	}
+	p7, err := pkcs7.Parse(p.Content)
+	if err != nil {
+		t.Fatalf("pkcs7.Parse: %v", err)
+	}
+	plaintext, err := p7.Decrypt(certificate, key)
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	part, err := parsePart(plaintext)
+	if err != nil {
+		t.Fatalf("parsePart: %v", err)
+	}
+	return part
+}
+
+func checkSMIMEText(t *testing.T, p *ParsedPart) {
+	if p.MediaType != "text/plain" || strings.TrimRight(string(p.Content), "\r\n") != testSMIMEText {
+		t.Errorf("content is %s %q, want text/plain %q", p.MediaType, p.Content, testSMIMEText)
+	}
+}
+
+func TestSMIMESignVerify(t *testing.T) {
+	certificate, key := newTestCertificate(t, 1, "sender@example.com")
+	parsed := exportSMIME(t, &SMIMEOptions{SignCertificate: certificate, SignKey: key})
+	checkSMIMEText(t, verifySMIME(t, parsed.Root, certificate))
+}
+
+func TestSMIMEEncryptDecrypt(t *testing.T) {
+	recipient, recipientKey := newTestCertificate(t, 2, "rcpt@example.org")
+	other, otherKey := newTestCertificate(t, 3, "other@example.org")
+	parsed := exportSMIME(t, &SMIMEOptions{EncryptTo: []*x509.Certificate{recipient, other}})
+
+	checkSMIMEText(t, decryptSMIME(t, parsed.Root, recipient, recipientKey))
+	checkSMIMEText(t, decryptSMIME(t, parsed.Root, other, otherKey))
+
+	p7, err := pkcs7.Parse(parsed.Root.Content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stranger, strangerKey := newTestCertificate(t, 4, "stranger@example.org")
+	if _, err := p7.Decrypt(stranger, strangerKey); err == nil {
+		t.Error("Decrypt without recipient key succeeded")
+	}
+}
+
+func TestSMIMESignEncrypt(t *testing.T) {
+	signer, signerKey := newTestCertificate(t, 5, "sender@example.com")
+	recipient, recipientKey := newTestCertificate(t, 6, "rcpt@example.org")
+	parsed := exportSMIME(t, &SMIMEOptions{SignCertificate: signer, SignKey: signerKey, EncryptTo: []*x509.Certificate{recipient}})
+
+	signed := decryptSMIME(t, parsed.Root, recipient, recipientKey)
+	checkSMIMEText(t, verifySMIME(t, signed, signer))
+}
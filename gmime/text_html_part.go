@@ -8,55 +8,94 @@ package gmime
 */
 import "C"
 import (
-	"reflect"
+	"strings"
 	"unsafe"
 )
 
-func bytesToString(b []byte) string {
-	bh := (*reflect.SliceHeader)(unsafe.Pointer(&b))
-	sh := reflect.StringHeader{bh.Data, bh.Len}
-	return *(*string)(unsafe.Pointer(&sh))
+// BodyOptions describes how text or html body is labelled and encoded,
+// exports fail with *HeaderError if Charset has CR or LF
+type BodyOptions struct {
+	Charset  string        // charset the body is written in, "utf-8" if empty
+	Encoding *EncodingType // Content-Transfer-Encoding, quoted-printable if nil
 }
 
 // returns MimePart as GMimeObject
 // caller is responsible for unref
-func mimePartFromBytes(body []byte) *C.GMimeObject {
-	text := C.CString(bytesToString(body))
-	defer C.free(unsafe.Pointer(text))
+func mimePartFromBytes(body []byte, subtype *C.char, options *BodyOptions) *C.GMimeObject {
+	content := dataWrapperFromBytes(body, EncodingDefault) // needs unref
+	defer C.g_object_unref(content)                        // unref
 
-	mem := C.g_mime_stream_mem_new_with_buffer(text, C.strlen(text))
-	defer C.g_object_unref(mem)
-
-	content := C.g_mime_data_wrapper_new_with_stream(mem, C.GMIME_CONTENT_ENCODING_DEFAULT)
-	defer C.g_object_unref(content)
-
-	part := C.g_mime_part_new_with_type(cStringText, cStringPlain)
+	part := C.g_mime_part_new_with_type(cStringText, subtype)
 	textPart := anyToGMimeObject(unsafe.Pointer(part))
 
-	C.g_mime_object_set_content_type_parameter(textPart, cStringCharset, cStringCharsetUTF8)
-	C.g_mime_part_set_content_encoding(part, C.GMIME_CONTENT_ENCODING_QUOTEDPRINTABLE)
+	charset := cStringCharsetUTF8
+	encoding := EncodingQuotedPrintable
+	if options != nil {
+		if options.Charset != "" {
+			charset = C.CString(options.Charset)  // needs free
+			defer C.free(unsafe.Pointer(charset)) // free
+		}
+		if options.Encoding != nil {
+			encoding = *options.Encoding
+		}
+	}
+
+	C.g_mime_object_set_content_type_parameter(textPart, cStringCharset, charset)
+	C.g_mime_part_set_content_encoding(part, (C.GMimeContentEncoding)(encoding))
 	C.g_mime_part_set_content_object(part, content)
 
 	return textPart
 }
 
+// bodyToUTF8 returns body converted from charset to UTF-8,
+// ok is false if GMime has no converter for the charset and body is returned as is
+func bodyToUTF8(body []byte, charset string) (converted []byte, ok bool) {
+	switch strings.ToLower(charset) {
+	case "", "utf-8", "utf8", "us-ascii":
+		return body, true
+	}
+	cCharset := C.CString(charset)                                             // needs free
+	filterCharset := C.g_mime_filter_charset_new(cCharset, cStringCharsetUTF8) // needs unref, NULL if conversion is not supported
+	C.free(unsafe.Pointer(cCharset))                                           // free
+	if filterCharset == nil {
+		return body, false
+	}
+	defer C.g_object_unref(filterCharset) // unref
+
+	rawStream := C.g_mime_stream_mem_new() // needs unref
+	defer C.g_object_unref(rawStream)      // unref
+
+	stream := C.g_mime_stream_filter_new(rawStream) // needs unref
+	defer C.g_object_unref(stream)                  // unref
+	C.g_mime_stream_filter_add((*C.GMimeStreamFilter)(unsafe.Pointer(stream)), filterCharset)
+
+	if len(body) > 0 {
+		C.g_mime_stream_write(stream, (*C.char)(unsafe.Pointer(&body[0])), C.size_t(len(body)))
+	}
+	C.g_mime_stream_flush(stream)
+
+	// byteArray is owned by rawStream and will be freed with it
+	byteArray := C.g_mime_stream_mem_get_byte_array((*C.GMimeStreamMem)(unsafe.Pointer(rawStream)))
+	return C.GoBytes(unsafe.Pointer(byteArray.data), (C.int)(byteArray.len)), true
+}
+
 // returns GMimeObject
 // caller responsible for unref
-func textHTMLPart(text, html []byte) (*C.GMimeObject, error) {
+func textHTMLPart(text, html []byte, textOptions, htmlOptions *BodyOptions, boundaries BoundaryGenerator) (*C.GMimeObject, error) {
 	var textPart, htmlPart *C.GMimeObject
 
 	if len(text) != 0 {
-		textPart = mimePartFromBytes(text)
+		textPart = mimePartFromBytes(text, cStringPlain, textOptions)
 	}
 
 	if len(html) != 0 {
-		htmlPart = mimePartFromBytes(html)
+		htmlPart = mimePartFromBytes(html, cStringHTML, htmlOptions)
 	}
 
 	switch {
 	case len(text) != 0 && len(html) != 0:
 		// should be multipart/alternative
-		multipart := C.g_mime_multipart_new_with_subtype(cStringAlternative)
+		multipart := newMultiPartWithSubtype(cStringAlternative, boundaries)
 		C.g_mime_multipart_add(multipart, textPart)
 		C.g_mime_multipart_add(multipart, htmlPart)
 		return anyToGMimeObject(unsafe.Pointer(multipart)), nil
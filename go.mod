@@ -0,0 +1,16 @@
+module github.com/sendgrid/go_gmime
+
+go 1.25.0
+
+require (
+	github.com/ProtonMail/go-crypto v1.3.0
+	go.mozilla.org/pkcs7 v0.10.0
+	golang.org/x/net v0.57.0
+)
+
+require (
+	github.com/cloudflare/circl v1.6.1 // indirect
+	golang.org/x/crypto v0.54.0 // indirect
+	golang.org/x/sys v0.47.0 // indirect
+	golang.org/x/text v0.40.0 // indirect
+)
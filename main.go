@@ -45,7 +45,7 @@ func main() {
 		Value: "test subject привет test subject привет test subject привет test subject привет test subject привет test subject привет",
 	})
 	msg.AppendHeader(&gmime.EmailHeader{
-		Name:  "custom header",
+		Name:  "X-Custom-Header",
 		Value: "some value here",
 	})
 